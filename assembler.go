@@ -12,10 +12,23 @@ import (
 func (a *Assembler) AssembleLine(line string) ([]byte, error) {
 	if a.Token == nil {
 		a.Token = NewToken(global, "", nil)
+		a.rvc = a.RVC
+	}
+	if a.equates == nil {
+		a.equates = equates{}
+	}
+	if err := a.applyMarch(); err != nil {
+		return nil, err
+	}
+	line, err := a.equates.expand(line)
+	if err != nil {
+		return nil, errors.New("LINE " + strconv.Itoa(a.lineNumber+1) + " " + err.Error())
+	}
+	lines, err := a.instructionSet().preprocessLine(line, a.Arch, a.isa)
+	if err != nil {
+		return nil, errors.New("LINE " + strconv.Itoa(a.lineNumber+1) + " " + err.Error())
 	}
-	lines := PreprocessLine(line)
 	actualParent := a.Token
-	var err error
 
 	for _, line := range lines {
 		lineParts := strings.Split(line, " ")
@@ -43,6 +56,10 @@ func (a *Assembler) Assemble(filename string, outputFolder string) error {
 
 	a.compilation.labelPositions = map[string]int{}
 	a.compilation.stringCount = 8
+	if err := a.applyMarch(); err != nil {
+		return err
+	}
+	a.rvc = a.RVC
 	if a.Token == nil {
 		a.Token = NewToken(global, "", nil)
 	}
@@ -53,7 +70,10 @@ func (a *Assembler) Assemble(filename string, outputFolder string) error {
 	defer file.Close()
 
 	//Preprocess File
-	var lines []string = Preprocess(file)
+	lines, err := a.instructionSet().preprocess(file, a.Arch, a.isa)
+	if err != nil {
+		return err
+	}
 
 	actualParent := a.Token
 	for _, line := range lines {
@@ -91,7 +111,7 @@ func (a *Assembler) Assemble(filename string, outputFolder string) error {
 		println(ferr.Error())
 	}
 	fmt.Println("blocks sizes")
-	fmt.Print("instructions (4bytes/instr): ")
+	fmt.Print("instructions (bytes): ")
 	fmt.Println(a.compilation.instructionCount)
 	fmt.Print("instructions count: ")
 	fmt.Println(a.compilation.instructionCount / 32)
@@ -147,6 +167,12 @@ func cleanupStr(str string) string {
 func (a *Assembler) Parse(lineParts []string, parent *Token) (*Token, error) {
 	ln := cleanupStr(lineParts[0])
 
+	if ln == ".option" {
+		return parent, a.parseOption(lineParts)
+	}
+	if ln == ".insn" {
+		return parent, a.parseInsn(lineParts, parent)
+	}
 	if ln[0] == '.' {
 		if parent.tokenType == global || parent.tokenType == section || parent.tokenType == globalLabel || parent.tokenType == constant {
 			if ln == ".section" {
@@ -265,21 +291,17 @@ func (a *Assembler) Parse(lineParts []string, parent *Token) (*Token, error) {
 		}
 	}
 	// either variable value or code line
-	instructionType, ok := InstructionToOpType[ln]
-	if !ok {
-		return parent, errors.New("Unknown instruction type: '" + ln + "'")
+	instructionType, err := a.lookupInstruction(ln)
+	if err != nil {
+		return parent, err
 	}
 
 	ptk := NewToken(instruction, ln, parent, &instructionType)
 	parent.children = append(parent.children, ptk)
 	lineParts = lineParts[1:]
-	var err error
 	//increase instruction count to keep track of machineCode Size
-	a.compilation.instructionCount += 4
+	a.compilation.instructionCount += instructionType.opType.size()
 
-	if ln == "ebreak" || ln == "ecall" {
-		return parent, nil
-	}
 	var newArr []string
 	for _, li := range lineParts {
 		splitArr := strings.Split(li, ",")
@@ -290,9 +312,15 @@ func (a *Assembler) Parse(lineParts []string, parent *Token) (*Token, error) {
 		}
 	}
 	lineParts = newArr
+	if err := a.instructionSet().checkOperands(ln, lineParts); err != nil {
+		return parent, err
+	}
 	switch instructionType.opType {
 	case R:
 		err = ParseRegisters(lineParts, ptk)
+		if err == nil {
+			err = checkRTypeOperands(ptk)
+		}
 	case I:
 		err = LexIType(lineParts, ptk)
 	case S:
@@ -304,21 +332,47 @@ func (a *Assembler) Parse(lineParts []string, parent *Token) (*Token, error) {
 	case J:
 		err = LexJType(lineParts, ptk)
 	case CI:
-		err = LexJType(lineParts, ptk)
+		err = LexCompressedType(lineParts, ptk)
 	case CSS:
-		err = LexJType(lineParts, ptk)
+		err = LexCompressedType(lineParts, ptk)
 	case CL:
-		err = LexJType(lineParts, ptk)
+		err = LexCompressedType(lineParts, ptk)
 	case CJ:
-		err = LexJType(lineParts, ptk)
+		err = LexCompressedType(lineParts, ptk)
 	case CR:
 		err = ParseRegisters(lineParts, ptk)
 	case CB:
-		err = LexJType(lineParts, ptk)
+		err = LexCompressedType(lineParts, ptk)
 	case CIW:
-		err = LexJType(lineParts, ptk)
+		err = LexCompressedType(lineParts, ptk)
 	case CS:
-		err = LexJType(lineParts, ptk)
+		err = LexCompressedType(lineParts, ptk)
+	case CA:
+		err = ParseRegisters(lineParts, ptk)
+	case A:
+		err = LexAType(lineParts, ptk)
+	case R4:
+		err = ParseRegisters(lineParts, ptk)
+	case CSR:
+		err = LexCSRType(lineParts, ptk)
+	case SYS:
+		err = ParseRegisters(lineParts, ptk)
+	case FENCE:
+		err = LexFenceType(lineParts, ptk)
+	case BS:
+		err = LexIType(lineParts, ptk)
+	case VSET:
+		err = LexVSetType(lineParts, ptk)
+	case VMEM:
+		err = LexVType(lineParts, ptk)
+	case V:
+		err = LexVType(lineParts, ptk)
+	case CMO:
+		err = LexIType(lineParts, ptk)
+	case CMPP:
+		err = LexRegisterListType(lineParts, ptk)
+	case CMMV:
+		err = ParseRegisters(lineParts, ptk)
 	default:
 		return parent, errors.New("unhandled OPTYPE for instruction:  '" + ln + "'")
 	}
@@ -326,21 +380,264 @@ func (a *Assembler) Parse(lineParts []string, parent *Token) (*Token, error) {
 	if err != nil {
 		return parent, err
 	}
+	if a.Arch == RV32E {
+		if err := checkRV32ERegisters(ptk); err != nil {
+			return parent, err
+		}
+	}
+	if a.rvc {
+		ptk.compressed = a.compressedForms(ptk)
+	}
 	return parent, nil
 }
 
+// checkRV32ERegisters rejects the integer registers x16-x31 that RV32E does not have,
+// float and vector registers keep their 32 entries
+func checkRV32ERegisters(t *Token) error {
+	for _, child := range t.children {
+		switch child.tokenType {
+		case register:
+			if reg, err := matchTokenValid(child.value); err == nil && reg > 15 {
+				return fmt.Errorf("register %s (x%d) is not available on RV32E, only x0-x15", child.value, reg)
+			}
+		case registerList:
+			// {ra, s0-s1} is rlist 6, s2 and above are x18-x27
+			if rlist, err := parseIntValue(child.value); err == nil && rlist > 6 {
+				return errors.New("register list saves s2-s11 which are not available on RV32E, only {ra, s0-s1}")
+			}
+		case complexValue:
+			if err := checkRV32ERegisters(child); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseInsn handles the .insn directive: a format followed by the opcode, the function
+// fields and the operands, .insn r CUSTOM_0, 0, 0, a0, a1, a2, encoded like the standard
+// instructions of that format, or a raw value such as .insn 0x0000000b
+func (a *Assembler) parseInsn(lineParts []string, parent *Token) error {
+	if len(lineParts) < 2 {
+		return errors.New(".insn: expected a format or a value")
+	}
+	name := cleanupStr(lineParts[1])
+	var args []string
+	for _, arg := range strings.Split(strings.Join(lineParts[2:], " "), ",") {
+		args = append(args, strings.TrimSpace(arg))
+	}
+	format, ok := insnFormats[name]
+	if !ok {
+		return a.parseRawInsn(append([]string{name}, args...), parent)
+	}
+	if len(args) <= len(format.fields)+1 {
+		return errors.New(".insn " + name + ": expected the opcode, function fields and operands")
+	}
+	opcode, ok := InsnOpcodes[args[0]]
+	if !ok {
+		var err error
+		opcode, err = parseIntValue(args[0])
+		if err != nil {
+			return errors.New(".insn " + name + ": unknown opcode " + args[0])
+		}
+	}
+	if opcode < 0 || opcode > 0x7F || opcode&0b11 != 0b11 {
+		return fmt.Errorf(".insn %s: opcode %#x is not a 32 bit major opcode", name, opcode)
+	}
+	opByte := []byte{byte(opcode)}
+	for i, maxVal := range format.fields {
+		field, err := parseIntValue(args[i+1])
+		if err != nil || field < 0 || field > maxVal {
+			return fmt.Errorf(".insn %s: function field %s is out of range 0-%d", name, args[i+1], maxVal)
+		}
+		opByte = append(opByte, byte(field))
+	}
+	if format.opType == I {
+		// no fixed imm[11:5]
+		opByte = append(opByte, 0)
+	}
+
+	ptk := NewToken(instruction, ".insn "+name, parent, &OpPair{format.opType, opByte})
+	ptk.insn = true
+	parent.children = append(parent.children, ptk)
+	a.compilation.instructionCount += format.opType.size()
+	operands := args[len(format.fields)+1:]
+	var err error
+	switch format.opType {
+	case R:
+		err = ParseRegisters(operands, ptk)
+		if err == nil {
+			err = checkRTypeOperands(ptk)
+		}
+	case R4:
+		err = ParseRegisters(operands, ptk)
+	case I:
+		err = LexIType(operands, ptk)
+	case S:
+		err = LexSType(operands, ptk)
+	case B:
+		err = LexBType(operands, ptk)
+	case U:
+		err = LexUType(operands, ptk)
+	case J:
+		err = LexJType(operands, ptk)
+	}
+	if err != nil {
+		return err
+	}
+	if a.Arch == RV32E {
+		return checkRV32ERegisters(ptk)
+	}
+	return nil
+}
+
+// parseRawInsn handles .insn value and .insn length, value, the length in bytes
+// must match the one the low bits of the value give: 4 when they are 11, else 2
+func (a *Assembler) parseRawInsn(args []string, parent *Token) error {
+	args = removeEmptyStrings(args)
+	length := 0
+	if len(args) == 2 {
+		var err error
+		length, err = parseIntValue(args[0])
+		if err != nil || (length != 2 && length != 4) {
+			return errors.New(".insn: length must be 2 or 4, got " + args[0])
+		}
+		args = args[1:]
+	}
+	if len(args) != 1 {
+		return errors.New(".insn: expected a format or a value")
+	}
+	val, err := parseInt64Value(args[0])
+	if err != nil {
+		return errors.New(".insn: unknown format " + args[0])
+	}
+	natural := 2
+	if val&0b11 == 0b11 {
+		natural = 4
+	}
+	if length == 0 {
+		length = natural
+	}
+	if length != natural || val < 0 || val >= 1<<(8*length) {
+		return fmt.Errorf(".insn: %s is not a %d byte instruction", args[0], length)
+	}
+	opType := INSN
+	if length == 2 {
+		opType = CINSN
+	}
+	opByte := make([]byte, length)
+	for i := range opByte {
+		opByte[i] = byte(val >> (8 * i))
+	}
+	parent.children = append(parent.children, NewToken(instruction, ".insn", parent, &OpPair{opType, opByte}))
+	a.compilation.instructionCount += length
+	return nil
+}
+
+// instructionSet returns the instructions a accepts, creating the standard set on first use
+func (a *Assembler) instructionSet() *InstructionSet {
+	if a.Instructions == nil {
+		a.Instructions = NewInstructionSet()
+	}
+	return a.Instructions
+}
+
+// lookupInstruction returns the encoding of an instruction for the target Arch
+func (a *Assembler) lookupInstruction(name string) (OpPair, error) {
+	pair, err := a.instructionSet().lookup(name, a.Arch)
+	if err != nil {
+		return OpPair{}, err
+	}
+	if a.isa != nil {
+		if err := a.isa.checkInstruction(name); err != nil {
+			return OpPair{}, err
+		}
+	}
+	return pair, nil
+}
+
+// applyMarch parses the March ISA string, the base it names replaces Arch, and hands
+// both to the compilation
+func (a *Assembler) applyMarch() error {
+	a.isa = nil
+	if a.March != "" {
+		isa, err := ParseISA(a.March)
+		if err != nil {
+			return err
+		}
+		a.isa = &isa
+		a.Arch = isa.Arch
+	}
+	a.compilation.arch = a.Arch
+	a.compilation.isa = a.isa
+	return nil
+}
+
+// parseOption handles the .option directive, rvc and norvc toggle the compression
+// of the following instructions, push and pop save and restore that setting
+func (a *Assembler) parseOption(lineParts []string) error {
+	if len(lineParts) != 2 {
+		return errors.New("invalid .option directive")
+	}
+	switch cleanupStr(lineParts[1]) {
+	case "rvc":
+		a.rvc = true
+	case "norvc":
+		a.rvc = false
+	case "push":
+		a.rvcStack = append(a.rvcStack, a.rvc)
+	case "pop":
+		if len(a.rvcStack) == 0 {
+			return errors.New(".option pop without matching .option push")
+		}
+		a.rvc = a.rvcStack[len(a.rvcStack)-1]
+		a.rvcStack = a.rvcStack[:len(a.rvcStack)-1]
+	default:
+		return errors.New("unknown .option " + lineParts[1])
+	}
+	return nil
+}
+
 func ParseRegisters(strArr []string, parent *Token) error { //todo check for errors
 	var numInst = 0
 	for _, str := range strArr {
 		if len(str) == 0 {
 			continue
 		}
-		parent.children = append(parent.children, NewToken(register, strings.TrimSpace(str), parent))
+		str = strings.TrimSpace(str)
+		if _, ok := roundingModes[str]; ok {
+			parent.children = append(parent.children, NewToken(roundingMode, str, parent))
+		} else {
+			parent.children = append(parent.children, NewToken(register, str, parent))
+		}
 		numInst++
 	}
 	return nil
 }
 
+// checkRTypeOperands verifies an R type instruction got rd, rs1, rs2 or, for the unary
+// forms with a fixed rs2 (clz, fsqrt.s...), only rd and rs1, plus an optional rounding mode
+func checkRTypeOperands(t *Token) error {
+	want := 3
+	if len(t.opPair.opByte) > 3 {
+		want = 2
+	}
+	var got int
+	for _, child := range t.children {
+		if child.tokenType != register {
+			continue
+		}
+		if _, err := child.getRegisterNumericValue(); err != nil {
+			return errors.New("R TYPE: " + t.value + ": invalid register " + child.value)
+		}
+		got++
+	}
+	if got != want {
+		return fmt.Errorf("R TYPE: %s expects %d register operands, got %d", t.value, want, got)
+	}
+	return nil
+}
+
 func LexIType(strArr []string, parent *Token) error {
 	err := ParseRegisters(strArr[:len(strArr)-1], parent)
 	if err != nil {
@@ -351,7 +648,9 @@ func LexIType(strArr []string, parent *Token) error {
 	if err != nil {
 		var vals = strings.Split(strArr[len(strArr)-1], "(")
 
-		if len(vals) != 2 {
+		if len(vals) == 3 {
+			return lexSymbolMemory(strArr[len(strArr)-1], parent)
+		} else if len(vals) != 2 {
 			cleanedStr := cleanupStr(vals[0])
 			if _, ok := matchTokenValid(cleanedStr); ok == nil {
 				parent.children = append(parent.children, NewToken(register, cleanedStr, parent))
@@ -360,7 +659,7 @@ func LexIType(strArr []string, parent *Token) error {
 			}
 		} else {
 			child := NewToken(complexValue, strArr[len(strArr)-1], parent)
-			adjustedVal, err = parseIntValue(cleanupStr(vals[0]))
+			adjustedVal, err = parseIntValue(memoryOffset(vals[0]))
 			if err != nil {
 
 				if strings.Contains(vals[0], "%") {
@@ -394,6 +693,44 @@ func LexIType(strArr []string, parent *Token) error {
 	return nil
 }
 
+// lexSymbolMemory lexes a %pcrel_lo(symbol)(register) operand into a complexValue
+// holding the modifier, the symbol and the base register
+func lexSymbolMemory(str string, parent *Token) error {
+	str = cleanupStr(str)
+	mod, rest, _ := strings.Cut(str, "(")
+	symbol, base, _ := strings.Cut(rest, ")(")
+	if !strings.HasPrefix(mod, "%") || !strings.HasSuffix(base, ")") {
+		return errors.New("invalid memory operand " + str)
+	}
+	base = strings.TrimSuffix(base, ")")
+	if _, err := matchTokenValid(base); err != nil {
+		return errors.New("invalid base register " + base)
+	}
+
+	child := NewToken(complexValue, str, parent)
+	child.children = append(child.children, NewToken(modifier, mod, child))
+	if _, err := parseIntValue(symbol); err == nil {
+		child.children = append(child.children, NewToken(literal, symbol, child))
+	} else if strings.Contains(symbol, ".") {
+		child.children = append(child.children, NewToken(constantValue, symbol, child))
+	} else {
+		child.children = append(child.children, NewToken(varValue, symbol, child))
+	}
+	child.children = append(child.children, NewToken(register, base, child))
+	parent.children = append(parent.children, child)
+	return nil
+}
+
+// memoryOffset returns the offset part of an offset(register) operand,
+// an empty offset as in (rs1) is read as 0(rs1)
+func memoryOffset(str string) string {
+	str = cleanupStr(str)
+	if str == "" {
+		return "0"
+	}
+	return str
+}
+
 func LexSType(strArr []string, parent *Token) error {
 	err := ParseRegisters(strArr[:len(strArr)-1], parent)
 	if err != nil {
@@ -403,7 +740,9 @@ func LexSType(strArr []string, parent *Token) error {
 	// check if format offset(register)
 	var vals = strings.Split(strArr[len(strArr)-1], "(")
 
-	if len(vals) != 2 {
+	if len(vals) == 3 {
+		return lexSymbolMemory(strArr[len(strArr)-1], parent)
+	} else if len(vals) != 2 {
 		cleanedStr := cleanupStr(vals[0])
 		if _, ok := matchTokenValid(cleanedStr); ok == nil {
 			parent.children = append(parent.children, NewToken(register, cleanedStr, parent))
@@ -412,7 +751,7 @@ func LexSType(strArr []string, parent *Token) error {
 		}
 	} else {
 		child := NewToken(complexValue, strArr[len(strArr)-1], parent)
-		adjustedVal, err := parseIntValue(cleanupStr(vals[0]))
+		adjustedVal, err := parseIntValue(memoryOffset(vals[0]))
 		if err != nil {
 
 			if strings.Contains(vals[0], "%") {
@@ -503,3 +842,271 @@ func LexUType(strArr []string, parent *Token) error {
 func LexJType(strArr []string, parent *Token) error {
 	return LexUType(strArr, parent)
 }
+
+// LexCompressedType lexes the operands of the 16 bit instructions,
+// their last operand is an immediate, a label or an offset(register) like in the I type
+func LexCompressedType(strArr []string, parent *Token) error {
+	strArr = removeEmptyStrings(strArr)
+	if len(strArr) == 0 {
+		return nil
+	}
+	return LexIType(strArr, parent)
+}
+
+// LexAType handles the atomic form rd, [rs2,] (rs1) where the address operand
+// is a bare register in parentheses (0(rs1) is accepted as well)
+func LexAType(strArr []string, parent *Token) error {
+	if len(strArr) < 2 {
+		return errors.New("A TYPE: expected at least 2 operands")
+	}
+	addr := cleanupStr(strArr[len(strArr)-1])
+	open := strings.Index(addr, "(")
+	if open == -1 || addr[len(addr)-1] != ')' {
+		return errors.New("A TYPE: address operand must be of the form (rs1) " + addr)
+	}
+	if offset, err := parseIntValue(memoryOffset(addr[:open])); err != nil || offset != 0 {
+		return errors.New("A TYPE: address operand does not take an offset " + addr)
+	}
+	return LexIType(strArr, parent)
+}
+
+// LexCSRType handles rd, csr, rs1 (or uimm for the immediate forms) where csr
+// is either a name from CSRNameToAddress or a 12 bit number
+func LexCSRType(strArr []string, parent *Token) error {
+	strArr = removeEmptyStrings(strArr)
+	if len(strArr) != 3 {
+		return errors.New("CSR TYPE: expected rd, csr, rs1")
+	}
+	err := ParseRegisters(strArr[:1], parent)
+	if err != nil {
+		return err
+	}
+
+	name := cleanupStr(strArr[1])
+	address, ok := CSRNameToAddress[name]
+	if !ok {
+		address, err = parseIntValue(name)
+		if err != nil || address < 0 || address > 0xFFF {
+			return errors.New("CSR TYPE: unknown CSR " + name)
+		}
+	}
+	parent.children = append(parent.children, NewToken(literal, strconv.Itoa(address), parent))
+
+	func3 := parent.opPair.opByte[1]
+	source := cleanupStr(strArr[2])
+	var sourceValue int
+	if func3&0b100 != 0 {
+		sourceValue, err = parseIntValue(source)
+		if err != nil || sourceValue < 0 || sourceValue > 31 {
+			return errors.New("CSR TYPE: immediate must be between 0 and 31 " + source)
+		}
+		parent.children = append(parent.children, NewToken(literal, strconv.Itoa(sourceValue), parent))
+	} else {
+		sourceValue, err = matchTokenValid(source)
+		if err != nil {
+			return errors.New("CSR TYPE: invalid source register " + source)
+		}
+		parent.children = append(parent.children, NewToken(register, source, parent))
+	}
+
+	// csrrw always writes, csrrs and csrrc only when rs1/uimm is not zero
+	writes := func3&0b11 == 0b01 || sourceValue != 0
+	if writes && isReadOnlyCSR(address) {
+		return fmt.Errorf("CSR TYPE: %s writes to read-only CSR %s (0x%03X)", parent.value, name, address)
+	}
+	return nil
+}
+
+// LexFenceType handles the optional pred, succ operands of fence, each one a set
+// of the letters i, o, r, w in that order (or 0 for the empty set)
+func LexFenceType(strArr []string, parent *Token) error {
+	strArr = removeEmptyStrings(strArr)
+	fixed := len(parent.opPair.opByte) > 3
+	if fixed && len(strArr) != 0 {
+		return errors.New("FENCE TYPE: " + parent.value + " does not take operands")
+	}
+	if len(strArr) == 0 {
+		if !fixed {
+			strArr = []string{"iorw", "iorw"}
+		}
+	} else if len(strArr) != 2 {
+		return errors.New("FENCE TYPE: expected pred, succ")
+	}
+	for _, str := range strArr {
+		set, err := parseFenceSet(cleanupStr(str))
+		if err != nil {
+			return err
+		}
+		parent.children = append(parent.children, NewToken(literal, strconv.Itoa(set), parent))
+	}
+	return nil
+}
+
+// parseFenceSet returns the 4 bit i/o/r/w mask of a fence operand
+func parseFenceSet(str string) (int, error) {
+	if str == "0" {
+		return 0, nil
+	}
+	const order = "iorw"
+	set := 0
+	last := -1
+	for _, ch := range str {
+		pos := strings.IndexRune(order, ch)
+		if pos <= last {
+			return 0, errors.New("FENCE TYPE: operand must be letters selected in order from iorw " + str)
+		}
+		last = pos
+		set |= 0b1000 >> pos
+	}
+	if set == 0 {
+		return 0, errors.New("FENCE TYPE: empty operand")
+	}
+	return set, nil
+}
+
+// LexVSetType handles rd, rs1 (or uimm for vsetivli) followed by the vtype
+// fields such as e32, m1, ta, ma which are folded into a literal
+func LexVSetType(strArr []string, parent *Token) error {
+	strArr = removeEmptyStrings(strArr)
+	if len(strArr) < 3 {
+		return errors.New("VSET TYPE: expected rd, rs1, vtype")
+	}
+	parent.children = append(parent.children, NewToken(register, cleanupStr(strArr[0]), parent))
+	avl := cleanupStr(strArr[1])
+	if val, err := parseIntValue(avl); err == nil {
+		parent.children = append(parent.children, NewToken(literal, strconv.Itoa(val), parent))
+	} else {
+		parent.children = append(parent.children, NewToken(register, avl, parent))
+	}
+	vtype, err := parseVType(strArr[2:])
+	if err != nil {
+		return err
+	}
+	parent.children = append(parent.children, NewToken(literal, strconv.Itoa(vtype), parent))
+	return nil
+}
+
+// parseVType returns the vtype immediate of the fields sew[, lmul][, ta|tu][, ma|mu],
+// omitted fields default to m1, tu and mu
+func parseVType(fields []string) (int, error) {
+	sew, ok := vectorSEW[cleanupStr(fields[0])]
+	if !ok {
+		return 0, errors.New("VSET TYPE: invalid element width " + fields[0])
+	}
+	vtype := sew << 3
+	fields = fields[1:]
+	if len(fields) > 0 {
+		if lmul, ok := vectorLMUL[cleanupStr(fields[0])]; ok {
+			vtype |= lmul
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 {
+		switch cleanupStr(fields[0]) {
+		case "ta":
+			vtype |= 1 << 6
+			fields = fields[1:]
+		case "tu":
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 {
+		switch cleanupStr(fields[0]) {
+		case "ma":
+			vtype |= 1 << 7
+			fields = fields[1:]
+		case "mu":
+			fields = fields[1:]
+		}
+	}
+	if len(fields) > 0 {
+		return 0, errors.New("VSET TYPE: unexpected vtype field " + fields[0])
+	}
+	return vtype, nil
+}
+
+// LexVType handles the vector operands: registers, immediates, the (rs1) base
+// of loads and stores and a trailing v0.t mask
+func LexVType(strArr []string, parent *Token) error {
+	for _, str := range removeEmptyStrings(strArr) {
+		str = cleanupStr(str)
+		if str == "v0.t" {
+			parent.children = append(parent.children, NewToken(vectorMask, str, parent))
+			continue
+		}
+		if val, err := parseIntValue(str); err == nil {
+			parent.children = append(parent.children, NewToken(literal, strconv.Itoa(val), parent))
+			continue
+		}
+		if vals := strings.Split(str, "("); len(vals) == 2 && strings.HasSuffix(vals[1], ")") {
+			if offset := memoryOffset(vals[0]); offset != "0" {
+				return errors.New("VMEM TYPE: offset must be 0 " + str)
+			}
+			str = vals[1][:len(vals[1])-1]
+		}
+		parent.children = append(parent.children, NewToken(register, str, parent))
+	}
+	return nil
+}
+
+// LexRegisterListType handles the {ra, s0-sN}, stack_adj operands of the Zcmp push and pop
+// instructions, the register list is folded into its 4 bit rlist encoding
+func LexRegisterListType(strArr []string, parent *Token) error {
+	operands := strings.Join(removeEmptyStrings(strArr), ",")
+	start, end := strings.Index(operands, "{"), strings.Index(operands, "}")
+	if start != 0 || end < 0 {
+		return errors.New("CMPP TYPE: expected {register list}, stack_adj")
+	}
+	rlist, err := parseRegisterList(operands[start+1 : end])
+	if err != nil {
+		return err
+	}
+	parent.children = append(parent.children, NewToken(registerList, strconv.Itoa(rlist), parent))
+	adj, err := parseIntValue(cleanupStr(operands[end+1:]))
+	if err != nil {
+		return errors.New("CMPP TYPE: stack adjustment is not a number " + operands[end+1:])
+	}
+	parent.children = append(parent.children, NewToken(literal, strconv.Itoa(adj), parent))
+	return nil
+}
+
+// parseRegisterList returns the rlist encoding of a {ra, s0-sN} list, 4 for {ra} up to
+// 15 for {ra, s0-s11}; ranges such as s0-s3 or x18-x20 run over the saved registers
+func parseRegisterList(list string) (int, error) {
+	invalid := errors.New("CMPP TYPE: invalid register list {" + list + "}")
+	items := strings.Split(list, ",")
+	if ra, err := matchTokenValid(strings.TrimSpace(items[0])); err != nil || ra != 1 {
+		return 0, invalid
+	}
+	saved := 0 // number of saved registers s0 to s(saved-1) in the list so far
+	for _, item := range items[1:] {
+		bounds := strings.Split(strings.TrimSpace(item), "-")
+		if len(bounds) > 2 {
+			return 0, invalid
+		}
+		var idx [2]int
+		for i, bound := range bounds {
+			reg, err := matchTokenValid(strings.TrimSpace(bound))
+			if err != nil {
+				return 0, invalid
+			}
+			idx[i] = savedRegisterIndex(reg)
+		}
+		if len(bounds) == 1 {
+			idx[1] = idx[0]
+		}
+		// the list must go on from the next saved register
+		if idx[0] != saved || idx[1] < idx[0] {
+			return 0, invalid
+		}
+		saved = idx[1] + 1
+	}
+	switch {
+	case saved == 12:
+		return 15, nil
+	case saved <= 10:
+		return 4 + saved, nil
+	}
+	// {ra, s0-s10} has no encoding
+	return 0, invalid
+}
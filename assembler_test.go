@@ -1,8 +1,10 @@
 package assembler
 
 import (
+	"encoding/binary"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -486,6 +488,33 @@ func TestLexIType(t *testing.T) {
 			wantErr: false,
 			wantLen: 2, // 1 register + 1 complex value
 		},
+		{
+			name: "I-type instruction with register only address",
+			args: args{
+				strArr: []string{"x1", "(x2)"},
+				parent: parent,
+			},
+			wantErr: false,
+			wantLen: 2, // 1 register + 1 complex value
+		},
+		{
+			name: "I-type instruction with symbol relative address",
+			args: args{
+				strArr: []string{"x1", "%pcrel_lo(counter)(x1)"},
+				parent: parent,
+			},
+			wantErr: false,
+			wantLen: 2, // 1 register + 1 complex value
+		},
+		{
+			name: "I-type instruction with symbol relative address and no base",
+			args: args{
+				strArr: []string{"x1", "%pcrel_lo(counter)(counter)"},
+				parent: parent,
+			},
+			wantErr: true,
+			wantLen: 1,
+		},
 		{
 			name: "I-type instruction with variable reference",
 			args: args{
@@ -731,3 +760,1070 @@ func TestLexJType(t *testing.T) {
 		})
 	}
 }
+
+// assembleLineWords assembles a single source line on a fresh Assembler and
+// returns the emitted machine code as little-endian 32-bit words.
+func assembleLineWords(line string, arch_optional ...Arch) ([]uint32, error) {
+	a := &Assembler{}
+	if len(arch_optional) > 0 {
+		a.Arch = arch_optional[0]
+	}
+	a.compilation.labelPositions = map[string]int{}
+	code, err := a.AssembleLine(line)
+	if err != nil {
+		return nil, err
+	}
+	words := make([]uint32, 0, len(code)/4)
+	for i := 0; i+4 <= len(code); i += 4 {
+		words = append(words, binary.LittleEndian.Uint32(code[i:]))
+	}
+	return words, nil
+}
+
+func assembleMarchWords(line string, march string) ([]uint32, error) {
+	a := &Assembler{March: march}
+	a.compilation.labelPositions = map[string]int{}
+	code, err := a.AssembleLine(line)
+	if err != nil {
+		return nil, err
+	}
+	words := make([]uint32, 0, len(code)/4)
+	for i := 0; i+4 <= len(code); i += 4 {
+		words = append(words, binary.LittleEndian.Uint32(code[i:]))
+	}
+	return words, nil
+}
+
+func TestAssembler_AssembleLine_Stores(t *testing.T) {
+	// the stored register is rs2 and the base rs1, distinct registers tell them apart
+	tests := []struct {
+		line string
+		want uint32
+	}{
+		{"sw a0, 4(a1)", 0x00A5A223},
+		{"sh a2, 8(a3)", 0x00C69423},
+		{"sb t0, -1(sp)", 0xFE510FA3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if err != nil {
+				t.Fatalf("AssembleLine(%q) error = %v", tt.line, err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_MExtension(t *testing.T) {
+	tests := []struct {
+		line string
+		want uint32
+	}{
+		{"mul a0, a1, a2", 0x02C58533},
+		{"mulh t0, t1, t2", 0x027312B3},
+		{"mulhsu s0, s1, a0", 0x02A4A433},
+		{"mulhu a0, a0, a0", 0x02A53533},
+		{"div x1, x2, x3", 0x023140B3},
+		{"divu x31, x30, x29", 0x03DF5FB3},
+		{"rem a5, a4, a3", 0x02D767B3},
+		{"remu zero, ra, sp", 0x0220F033},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if err != nil {
+				t.Fatalf("AssembleLine(%q) error = %v", tt.line, err)
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_AExtension(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint32
+		wantErr bool
+	}{
+		{"lr.w a0, (a1)", 0x1005A52F, false},
+		{"lr.w.aq t0, (sp)", 0x140122AF, false},
+		{"lr.w a0, 0(a1)", 0x1005A52F, false},
+		{"sc.w a0, a2, (a1)", 0x18C5A52F, false},
+		{"sc.w.rl t1, t2, (s0)", 0x1A74232F, false},
+		{"amoswap.w.aqrl a0, a1, (a2)", 0x0EB6252F, false},
+		{"amoadd.w a0, a1, (a2)", 0x00B6252F, false},
+		{"amoand.w.aq t0, t1, (t2)", 0x6463A2AF, false},
+		{"amoor.w s1, s2, (s3)", 0x4129A4AF, false},
+		{"amoxor.w a5, a4, (a3)", 0x20E6A7AF, false},
+		{"amomin.w a0, a1, (a2)", 0x80B6252F, false},
+		{"amomax.w.rl a0, a1, (a2)", 0xA2B6252F, false},
+		{"amominu.w a0, a1, (a2)", 0xC0B6252F, false},
+		{"amomaxu.w.aqrl x31, x30, (x29)", 0xE7EEAFAF, false},
+		{"amoadd.w a0, a1, 4(a2)", 0, true},
+		{"amoadd.w a0, a1, a2", 0, true},
+		{"sc.w a0, (a1)", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(got) != 1 || got[0] != tt.want) {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_FExtension(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint32
+		wantErr bool
+	}{
+		{"flw fa0, 8(a1)", 0x0085A507, false},
+		{"flw ft0, (sp)", 0x00012007, false},
+		{"fsw fa0, 8(a1)", 0x00A5A427, false},
+		{"fmadd.s fa0, fa1, fa2, fa3", 0x68C5F543, false},
+		{"fmsub.s ft0, ft1, ft2, ft3, rtz", 0x18209047, false},
+		{"fnmsub.s fs0, fs1, fs2, fs3", 0x9924F44B, false},
+		{"fnmadd.s f0, f1, f2, f3, rne", 0x1820804F, false},
+		{"fadd.s fa0, fa1, fa2", 0x00C5F553, false},
+		{"fadd.s fa0, fa1, fa2, rtz", 0x00C59553, false},
+		{"fsub.s ft0, ft1, ft2", 0x0820F053, false},
+		{"fmul.s fs0, fs1, fs2, rdn", 0x1124A453, false},
+		{"fdiv.s ft8, ft9, ft10, rup", 0x19EEBE53, false},
+		{"fsqrt.s fa0, fa1", 0x5805F553, false},
+		{"fsqrt.s fa0, fa1, rmm", 0x5805C553, false},
+		{"fsgnj.s fa0, fa1, fa2", 0x20C58553, false},
+		{"fsgnjn.s fa0, fa1, fa2", 0x20C59553, false},
+		{"fsgnjx.s fa0, fa1, fa2", 0x20C5A553, false},
+		{"fmin.s ft0, ft1, ft2", 0x28208053, false},
+		{"fmax.s ft0, ft1, ft2", 0x28209053, false},
+		{"fcvt.w.s a0, fa0, rtz", 0xC0051553, false},
+		{"fcvt.wu.s a0, fa0", 0xC0157553, false},
+		{"fmv.x.w a0, fa0", 0xE0050553, false},
+		{"feq.s a0, fa0, fa1", 0xA0B52553, false},
+		{"flt.s a0, fa0, fa1", 0xA0B51553, false},
+		{"fle.s a0, fa0, fa1", 0xA0B50553, false},
+		{"fclass.s a0, fa0", 0xE0051553, false},
+		{"fcvt.s.w fa0, a0", 0xD0057553, false},
+		{"fcvt.s.wu fa0, a0, rne", 0xD0150553, false},
+		{"fmv.w.x fa0, a0", 0xF0050553, false},
+		{"fmv.s fa0, fa1", 0x20B58553, false},
+		{"fabs.s fa0, fa1", 0x20B5A553, false},
+		{"fneg.s fa0, fa1", 0x20B59553, false},
+		{"fadd.s f31, fs11, ft11", 0x01FDFFD3, false},
+		{"feq.s a0, fa0, fa1, rtz", 0, true},
+		{"fsqrt.s fa0, fa1, fa2", 0, true},
+		{"fmadd.s fa0, fa1, fa2", 0, true},
+		{"add a0, fa1, a2", 0, true},
+		{"lw fa0, 4(a1)", 0, true},
+		{"flw fa0, 8(fa1)", 0, true},
+		{"fadd.s fa0, a1, fa2", 0, true},
+		{"fcvt.w.s fa0, fa0", 0, true},
+		{"fmv.w.x fa0, fa1", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(got) != 1 || got[0] != tt.want) {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_DExtension(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint32
+		wantErr bool
+	}{
+		{"fld fa0, 8(a1)", 0x0085B507, false},
+		{"fsd fa0, 8(a1)", 0x00A5B427, false},
+		{"fmadd.d fa0, fa1, fa2, fa3", 0x6AC5F543, false},
+		{"fmsub.d ft0, ft1, ft2, ft3, rtz", 0x1A209047, false},
+		{"fnmsub.d fs0, fs1, fs2, fs3", 0x9B24F44B, false},
+		{"fnmadd.d f0, f1, f2, f3", 0x1A20F04F, false},
+		{"fadd.d fa0, fa1, fa2", 0x02C5F553, false},
+		{"fsub.d fa0, fa1, fa2, rtz", 0x0AC59553, false},
+		{"fmul.d ft0, ft1, ft2", 0x1220F053, false},
+		{"fdiv.d fs0, fs1, fs2", 0x1B24F453, false},
+		{"fsqrt.d fa0, fa1", 0x5A05F553, false},
+		{"fsgnj.d fa0, fa1, fa2", 0x22C58553, false},
+		{"fsgnjn.d fa0, fa1, fa2", 0x22C59553, false},
+		{"fsgnjx.d fa0, fa1, fa2", 0x22C5A553, false},
+		{"fmin.d ft0, ft1, ft2", 0x2A208053, false},
+		{"fmax.d ft0, ft1, ft2", 0x2A209053, false},
+		{"fcvt.s.d fa0, fa1", 0x4015F553, false},
+		{"fcvt.s.d fa0, fa1, rne", 0x40158553, false},
+		{"fcvt.d.s fa0, fa1", 0x42058553, false},
+		{"feq.d a0, fa0, fa1", 0xA2B52553, false},
+		{"flt.d a0, fa0, fa1", 0xA2B51553, false},
+		{"fle.d a0, fa0, fa1", 0xA2B50553, false},
+		{"fclass.d a0, fa0", 0xE2051553, false},
+		{"fcvt.w.d a0, fa0, rtz", 0xC2051553, false},
+		{"fcvt.wu.d a0, fa0", 0xC2157553, false},
+		{"fcvt.d.w fa0, a0", 0xD2050553, false},
+		{"fcvt.d.wu fa0, a0", 0xD2150553, false},
+		{"fmv.d fa0, fa1", 0x22B58553, false},
+		{"fabs.d fa0, fa1", 0x22B5A553, false},
+		{"fneg.d fa0, fa1", 0x22B59553, false},
+		{"fcvt.d.w fa0, a0, rtz", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(got) != 1 || got[0] != tt.want) {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_Zicsr(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint32
+		wantErr bool
+	}{
+		{"csrrw a0, mstatus, a1", 0x30059573, false},
+		{"csrrs t0, mtvec, zero", 0x305022F3, false},
+		{"csrrc a0, mie, a2", 0x30463573, false},
+		{"csrrwi a0, mscratch, 5", 0x3402D573, false},
+		{"csrrsi zero, mstatus, 8", 0x30046073, false},
+		{"csrrci a0, 0x300, 31", 0x300FF573, false},
+		{"csrr a0, mcause", 0x34202573, false},
+		{"csrw mepc, a0", 0x34151073, false},
+		{"csrs mie, t0", 0x3042A073, false},
+		{"csrc mip, t1", 0x34433073, false},
+		{"csrwi mstatus, 3", 0x3001D073, false},
+		{"csrsi mstatus, 8", 0x30046073, false},
+		{"csrci mstatus, 8", 0x30047073, false},
+		{"rdcycle a0", 0xC0002573, false},
+		{"rdtime a1", 0xC01025F3, false},
+		{"rdinstret a2", 0xC0202673, false},
+		{"rdcycleh a0", 0xC8002573, false},
+		{"rdtimeh a0", 0xC8102573, false},
+		{"rdinstreth a0", 0xC8202573, false},
+		{"csrr a0, 0xC00", 0xC0002573, false},
+		{"csrr a0, mhartid", 0xF1402573, false},
+		{"csrw cycle, a0", 0, true},
+		{"csrrw zero, mhartid, zero", 0, true},
+		{"csrrsi a0, 0xC01, 1", 0, true},
+		{"csrr a0, notacsr", 0, true},
+		{"csrrwi a0, mstatus, 32", 0, true},
+		{"csrrw a0, 0x1000, a1", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(got) != 1 || got[0] != tt.want) {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_Privileged(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint32
+		wantErr bool
+	}{
+		{"ecall", 0x00000073, false},
+		{"ebreak", 0x00100073, false},
+		{"uret", 0x00200073, false},
+		{"sret", 0x10200073, false},
+		{"mret", 0x30200073, false},
+		{"dret", 0x7B200073, false},
+		{"wfi", 0x10500073, false},
+		{"sfence.vma", 0x12000073, false},
+		{"sfence.vma a0", 0x12050073, false},
+		{"sfence.vma a0, a1", 0x12B50073, false},
+		{"mret a0", 0, true},
+		{"sfence.vma a0, a1, a2", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(got) != 1 || got[0] != tt.want) {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_Fence(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint32
+		wantErr bool
+	}{
+		{"fence", 0x0FF0000F, false},
+		{"fence iorw, iorw", 0x0FF0000F, false},
+		{"fence rw, w", 0x0310000F, false},
+		{"fence i, o", 0x0840000F, false},
+		{"fence r, rw", 0x0230000F, false},
+		{"fence w, 0", 0x0100000F, false},
+		{"fence.tso", 0x8330000F, false},
+		{"pause", 0x0100000F, false},
+		{"fence.i", 0x0000100F, false},
+		{"fence rw", 0, true},
+		{"fence wr, rw", 0, true},
+		{"fence rx, rw", 0, true},
+		{"fence.i rw, rw", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !tt.wantErr && (len(got) != 1 || got[0] != tt.want) {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_RV64(t *testing.T) {
+	tests := []struct {
+		line    string
+		arch    Arch
+		want    []uint32
+		wantErr bool
+	}{
+		{"ld a0, 8(a1)", RV64, []uint32{0x0085B503}, false},
+		{"lwu t0, -4(sp)", RV64, []uint32{0xFFC16283}, false},
+		{"sd a0, 16(a1)", RV64, []uint32{0x00A5B823}, false},
+		{"addiw a0, a1, -1", RV64, []uint32{0xFFF5851B}, false},
+		{"slliw a0, a1, 31", RV64, []uint32{0x01F5951B}, false},
+		{"srliw a0, a1, 3", RV64, []uint32{0x0035D51B}, false},
+		{"sraiw a0, a1, 3", RV64, []uint32{0x4035D51B}, false},
+		{"slli a0, a1, 63", RV64, []uint32{0x03F59513}, false},
+		{"srli a0, a1, 32", RV64, []uint32{0x0205D513}, false},
+		{"srai a0, a1, 40", RV64, []uint32{0x4285D513}, false},
+		{"addw a0, a1, a2", RV64, []uint32{0x00C5853B}, false},
+		{"subw a0, a1, a2", RV64, []uint32{0x40C5853B}, false},
+		{"sllw a0, a1, a2", RV64, []uint32{0x00C5953B}, false},
+		{"srlw a0, a1, a2", RV64, []uint32{0x00C5D53B}, false},
+		{"sraw a0, a1, a2", RV64, []uint32{0x40C5D53B}, false},
+		{"mulw a0, a1, a2", RV64, []uint32{0x02C5853B}, false},
+		{"divw a0, a1, a2", RV64, []uint32{0x02C5C53B}, false},
+		{"divuw a0, a1, a2", RV64, []uint32{0x02C5D53B}, false},
+		{"remw a0, a1, a2", RV64, []uint32{0x02C5E53B}, false},
+		{"remuw a0, a1, a2", RV64, []uint32{0x02C5F53B}, false},
+		{"lr.d a0, (a1)", RV64, []uint32{0x1005B52F}, false},
+		{"sc.d.rl a0, a2, (a1)", RV64, []uint32{0x1AC5B52F}, false},
+		{"amoadd.d.aqrl a0, a1, (a2)", RV64, []uint32{0x06B6352F}, false},
+		{"fcvt.l.s a0, fa0, rtz", RV64, []uint32{0xC0251553}, false},
+		{"fcvt.lu.d a0, fa0", RV64, []uint32{0xC2357553}, false},
+		{"fcvt.d.l fa0, a0", RV64, []uint32{0xD2257553}, false},
+		{"fcvt.s.lu fa0, a0", RV64, []uint32{0xD0357553}, false},
+		{"fmv.x.d a0, fa0", RV64, []uint32{0xE2050553}, false},
+		{"fmv.d.x fa0, a0", RV64, []uint32{0xF2050553}, false},
+		{"sext.w a0, a1", RV64, []uint32{0x0005851B}, false},
+		{"negw a0, a1", RV64, []uint32{0x40B0053B}, false},
+		{"li a0, 0x7FFFFFFF", RV64, []uint32{0x80000537, 0xFFF5051B}, false},
+		{"li a0, 0x80000000", RV64, []uint32{0x00100513, 0x01F51513}, false},
+		{"li a0, -1", RV64, []uint32{0xFFF00513}, false},
+		{"slli a0, a1, 64", RV64, nil, true},
+		{"slliw a0, a1, 32", RV64, nil, true},
+		{"ld a0, 8(a1)", RV32, nil, true},
+		{"addw a0, a1, a2", RV32, nil, true},
+		{"slli a0, a1, 32", RV32, nil, true},
+		{"slli a0, a1, 31", RV32, []uint32{0x01F59513}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.arch.String()+" "+tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line, tt.arch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func assembleLineHalfwords(line string, arch_optional ...Arch) ([]uint16, error) {
+	a := &Assembler{}
+	if len(arch_optional) > 0 {
+		a.Arch = arch_optional[0]
+	}
+	a.compilation.labelPositions = map[string]int{}
+	code, err := a.AssembleLine(line)
+	if err != nil {
+		return nil, err
+	}
+	halfwords := make([]uint16, 0, len(code)/2)
+	for i := 0; i+2 <= len(code); i += 2 {
+		halfwords = append(halfwords, binary.LittleEndian.Uint16(code[i:]))
+	}
+	return halfwords, nil
+}
+
+func TestAssembler_AssembleLine_CExtension(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint16
+		wantErr bool
+	}{
+		{line: "c.addi4spn a0, sp, 16", want: 0x0808},
+		{line: "c.addi4spn s0, sp, 1020", want: 0x1FE0},
+		{line: "c.lw a0, 4(a1)", want: 0x41C8},
+		{line: "c.lw s1, 124(a5)", want: 0x5FE4},
+		{line: "c.sw a0, 4(a1)", want: 0xC1C8},
+		{line: "c.flw fa0, 4(a1)", want: 0x61C8},
+		{line: "c.fsw fa0, 4(a1)", want: 0xE1C8},
+		{line: "c.fld fa0, 8(a1)", want: 0x2588},
+		{line: "c.fsd fa0, 248(a1)", want: 0xBDE8},
+		{line: "c.nop", want: 0x0001},
+		{line: "c.addi a0, -1", want: 0x157D},
+		{line: "c.addi a0, 31", want: 0x057D},
+		{line: "c.jal 16", want: 0x2801},
+		{line: "c.jal -2048", want: 0x3001},
+		{line: "c.li a0, 5", want: 0x4515},
+		{line: "c.addi16sp sp, -64", want: 0x7139},
+		{line: "c.addi16sp sp, 496", want: 0x617D},
+		{line: "c.lui a0, 1", want: 0x6505},
+		{line: "c.lui a0, 0xfffff", want: 0x757D},
+		{line: "c.lui t0, 31", want: 0x62FD},
+		{line: "c.srli a0, 3", want: 0x810D},
+		{line: "c.srai a0, 31", want: 0x857D},
+		{line: "c.andi a0, -1", want: 0x997D},
+		{line: "c.sub a0, a1", want: 0x8D0D},
+		{line: "c.xor a0, a1", want: 0x8D2D},
+		{line: "c.or a0, a1", want: 0x8D4D},
+		{line: "c.and s0, a5", want: 0x8C7D},
+		{line: "c.j -16", want: 0xBFC5},
+		{line: "c.j 2046", want: 0xAFFD},
+		{line: "c.beqz a0, 8", want: 0xC501},
+		{line: "c.bnez a0, -8", want: 0xFD65},
+		{line: "c.beqz a0, -256", want: 0xD101},
+		{line: "c.slli a0, 3", want: 0x050E},
+		{line: "c.fldsp fa0, 8(sp)", want: 0x2522},
+		{line: "c.lwsp a0, 4(sp)", want: 0x4512},
+		{line: "c.lwsp ra, 252(sp)", want: 0x50FE},
+		{line: "c.flwsp fa0, 4(sp)", want: 0x6512},
+		{line: "c.jr ra", want: 0x8082},
+		{line: "c.mv a0, a1", want: 0x852E},
+		{line: "c.ebreak", want: 0x9002},
+		{line: "c.jalr a0", want: 0x9502},
+		{line: "c.add a0, a1", want: 0x952E},
+		{line: "c.fsdsp fa0, 8(sp)", want: 0xA42A},
+		{line: "c.swsp a0, 4(sp)", want: 0xC22A},
+		{line: "c.swsp ra, 252(sp)", want: 0xDF86},
+		{line: "c.fswsp fa0, 4(sp)", want: 0xE22A},
+		{line: "c.lw a0, 4(sp)", wantErr: true},
+		{line: "c.lw a0, 2(a1)", wantErr: true},
+		{line: "c.lw a0, 128(a1)", wantErr: true},
+		{line: "c.sub a0, t0", wantErr: true},
+		{line: "c.addi a0, 32", wantErr: true},
+		{line: "c.addi4spn a0, sp, 0", wantErr: true},
+		{line: "c.addi16sp a0, 16", wantErr: true},
+		{line: "c.lui sp, 1", wantErr: true},
+		{line: "c.lui a0, 0", wantErr: true},
+		{line: "c.lwsp a0, 4(a1)", wantErr: true},
+		{line: "c.slli a0, 32", wantErr: true},
+		{line: "c.beqz a0, 7", wantErr: true},
+		{line: "c.j 2048", wantErr: true},
+		{line: "c.mv a0, x0", wantErr: true},
+		{line: "c.jr x0", wantErr: true},
+		{line: "c.ld a0, 8(a1)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineHalfwords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("AssembleLine(%q) = %#04x, want %#04x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_CExtensionRV64(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint16
+		wantErr bool
+	}{
+		{line: "c.ld a0, 8(a1)", want: 0x6588},
+		{line: "c.sd a0, 248(a1)", want: 0xFDE8},
+		{line: "c.addiw a0, -1", want: 0x357D},
+		{line: "c.ldsp a0, 504(sp)", want: 0x757E},
+		{line: "c.sdsp a0, 8(sp)", want: 0xE42A},
+		{line: "c.subw a0, a1", want: 0x9D0D},
+		{line: "c.addw a0, a1", want: 0x9D2D},
+		{line: "c.slli a0, 63", want: 0x157E},
+		{line: "c.srli a0, 32", want: 0x9101},
+		{line: "c.flw fa0, 4(a1)", wantErr: true},
+		{line: "c.jal 16", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineHalfwords(tt.line, RV64)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("AssembleLine(%q) = %#04x, want %#04x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_Bitmanip(t *testing.T) {
+	tests := []struct {
+		line    string
+		arch    Arch
+		march   string
+		want    uint32
+		wantErr bool
+	}{
+		{line: "sh1add a0, a1, a2", want: 0x20C5A533},
+		{line: "sh2add a0, a1, a2", want: 0x20C5C533},
+		{line: "sh3add a0, a1, a2", want: 0x20C5E533},
+		{line: "andn a0, a1, a2", want: 0x40C5F533},
+		{line: "orn a0, a1, a2", want: 0x40C5E533},
+		{line: "xnor a0, a1, a2", want: 0x40C5C533},
+		{line: "clz a0, a1", want: 0x60059513},
+		{line: "ctz a0, a1", want: 0x60159513},
+		{line: "cpop a0, a1", want: 0x60259513},
+		{line: "max a0, a1, a2", want: 0x0AC5E533},
+		{line: "maxu a0, a1, a2", want: 0x0AC5F533},
+		{line: "min a0, a1, a2", want: 0x0AC5C533},
+		{line: "minu a0, a1, a2", want: 0x0AC5D533},
+		{line: "sext.b a0, a1", march: "rv32i_zbb", want: 0x60459513},
+		{line: "sext.h a0, a1", march: "rv32i_zbb", want: 0x60559513},
+		{line: "zext.h a0, a1", march: "rv32i_zbb", want: 0x0805C533},
+		{line: "rol a0, a1, a2", want: 0x60C59533},
+		{line: "ror a0, a1, a2", want: 0x60C5D533},
+		{line: "rori a0, a1, 31", want: 0x61F5D513},
+		{line: "orc.b a0, a1", want: 0x2875D513},
+		{line: "rev8 a0, a1", want: 0x6985D513},
+		{line: "bclr a0, a1, a2", want: 0x48C59533},
+		{line: "bclri a0, a1, 31", want: 0x49F59513},
+		{line: "bext a0, a1, a2", want: 0x48C5D533},
+		{line: "bexti a0, a1, 3", want: 0x4835D513},
+		{line: "binv a0, a1, a2", want: 0x68C59533},
+		{line: "binvi a0, a1, 3", want: 0x68359513},
+		{line: "bset a0, a1, a2", want: 0x28C59533},
+		{line: "bseti a0, a1, 3", want: 0x28359513},
+		{line: "add.uw a0, a1, a2", arch: RV64, want: 0x08C5853B},
+		{line: "sh1add.uw a0, a1, a2", arch: RV64, want: 0x20C5A53B},
+		{line: "sh2add.uw a0, a1, a2", arch: RV64, want: 0x20C5C53B},
+		{line: "sh3add.uw a0, a1, a2", arch: RV64, want: 0x20C5E53B},
+		{line: "slli.uw a0, a1, 63", arch: RV64, want: 0x0BF5951B},
+		{line: "zext.w a0, a1", march: "rv64i_zba", want: 0x0805853B},
+		{line: "clzw a0, a1", arch: RV64, want: 0x6005951B},
+		{line: "ctzw a0, a1", arch: RV64, want: 0x6015951B},
+		{line: "cpopw a0, a1", arch: RV64, want: 0x6025951B},
+		{line: "rolw a0, a1, a2", arch: RV64, want: 0x60C5953B},
+		{line: "rorw a0, a1, a2", arch: RV64, want: 0x60C5D53B},
+		{line: "roriw a0, a1, 31", arch: RV64, want: 0x61F5D51B},
+		{line: "rori a0, a1, 63", arch: RV64, want: 0x63F5D513},
+		{line: "rev8 a0, a1", arch: RV64, want: 0x6B85D513},
+		{line: "zext.h a0, a1", march: "rv64i_zbb", want: 0x0805C53B},
+		{line: "bseti a0, a1, 63", arch: RV64, want: 0x2BF59513},
+		{line: "clz a0", wantErr: true},
+		{line: "clz a0, a1, a2", wantErr: true},
+		{line: "andn a0, a1", wantErr: true},
+		{line: "orc.b a0, foo", wantErr: true},
+		{line: "rori a0, a1, 32", wantErr: true},
+		{line: "bexti a0, a1, 32", wantErr: true},
+		{line: "roriw a0, a1, 32", arch: RV64, wantErr: true},
+		{line: "zext.w a0, a1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arch.String()+" "+tt.march+" "+tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line, tt.arch)
+			if tt.march != "" {
+				got, err = assembleMarchWords(tt.line, tt.march)
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("AssembleLine(%q) = %#08x, want %#08x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_ScalarCrypto(t *testing.T) {
+	tests := []struct {
+		line    string
+		arch    Arch
+		want    uint32
+		wantErr bool
+	}{
+		{line: "pack a0, a1, a2", want: 0x08C5C533},
+		{line: "packh a0, a1, a2", want: 0x08C5F533},
+		{line: "brev8 a0, a1", want: 0x6875D513},
+		{line: "zip a0, a1", want: 0x08F59513},
+		{line: "unzip a0, a1", want: 0x08F5D513},
+		{line: "clmul a0, a1, a2", want: 0x0AC59533},
+		{line: "clmulh a0, a1, a2", want: 0x0AC5B533},
+		{line: "sha256sig0 a0, a1", want: 0x10259513},
+		{line: "sha256sig1 a0, a1", want: 0x10359513},
+		{line: "sha256sum0 a0, a1", want: 0x10059513},
+		{line: "sha256sum1 a0, a1", want: 0x10159513},
+		{line: "sha512sig0h a0, a1, a2", want: 0x5CC58533},
+		{line: "sha512sig0l a0, a1, a2", want: 0x54C58533},
+		{line: "sha512sig1h a0, a1, a2", want: 0x5EC58533},
+		{line: "sha512sig1l a0, a1, a2", want: 0x56C58533},
+		{line: "sha512sum0r a0, a1, a2", want: 0x50C58533},
+		{line: "sha512sum1r a0, a1, a2", want: 0x52C58533},
+		{line: "aes32esi a0, a1, a2, 3", want: 0xE2C58533},
+		{line: "aes32esmi a0, a1, a2, 1", want: 0x66C58533},
+		{line: "aes32dsi a0, a1, a2, 2", want: 0xAAC58533},
+		{line: "aes32dsmi a0, a1, a2, 0", want: 0x2EC58533},
+		{line: "packw a0, a1, a2", arch: RV64, want: 0x08C5C53B},
+		{line: "sha512sig0 a0, a1", arch: RV64, want: 0x10659513},
+		{line: "sha512sig1 a0, a1", arch: RV64, want: 0x10759513},
+		{line: "sha512sum0 a0, a1", arch: RV64, want: 0x10459513},
+		{line: "sha512sum1 a0, a1", arch: RV64, want: 0x10559513},
+		{line: "aes32esi a0, a1, a2, 4", wantErr: true},
+		{line: "aes32esi a0, a1, a2", wantErr: true},
+		{line: "aes32esi a0, a1, a2, a3", wantErr: true},
+		{line: "aes32esi a0, a1, a2, 0", arch: RV64, wantErr: true},
+		{line: "sha256sig0 a0, a1, a2", wantErr: true},
+		{line: "sha512sig0 a0, a1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arch.String()+" "+tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line, tt.arch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("AssembleLine(%q) = %#08x, want %#08x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_Vector(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint32
+		wantErr bool
+	}{
+		{line: "vsetvli t0, a0, e32, m1, ta, ma", want: 0x0D0572D7},
+		{line: "vsetvli a0, a1, e8, mf2, tu, mu", want: 0x0075F557},
+		{line: "vsetvli a0, a1, e64, m8, ta, mu", want: 0x05B5F557},
+		{line: "vsetvli a0, a1, e16", want: 0x0085F557},
+		{line: "vsetivli t0, 16, e32, m1, ta, ma", want: 0xCD0872D7},
+		{line: "vsetivli a0, 31, e8, m4, tu, ma", want: 0xC82FF557},
+		{line: "vsetvl a0, a1, a2", want: 0x80C5F557},
+		{line: "vle32.v v8, (a0)", want: 0x02056407},
+		{line: "vle32.v v8, (a0), v0.t", want: 0x00056407},
+		{line: "vle32.v v8, 0(a0)", want: 0x02056407},
+		{line: "vse32.v v8, (a1)", want: 0x0205E427},
+		{line: "vse32.v v4, (a1), v0.t", want: 0x0005E227},
+		{line: "vle8.v v1, (a0)", want: 0x02050087},
+		{line: "vle16.v v1, (a0)", want: 0x02055087},
+		{line: "vle64.v v1, (a0)", want: 0x02057087},
+		{line: "vse8.v v1, (a0)", want: 0x020500A7},
+		{line: "vse16.v v1, (a0)", want: 0x020550A7},
+		{line: "vse64.v v1, (a0)", want: 0x020570A7},
+		{line: "vadd.vv v1, v2, v3", want: 0x022180D7},
+		{line: "vadd.vv v1, v2, v3, v0.t", want: 0x002180D7},
+		{line: "vadd.vx v1, v2, a0", want: 0x022540D7},
+		{line: "vadd.vx v1, v2, a0, v0.t", want: 0x002540D7},
+		{line: "vadd.vi v1, v2, -16", want: 0x022830D7},
+		{line: "vadd.vi v1, v2, 15, v0.t", want: 0x0027B0D7},
+		{line: "vsub.vv v1, v2, v3", want: 0x0A2180D7},
+		{line: "vsub.vx v1, v2, a0", want: 0x0A2540D7},
+		{line: "vrsub.vx v1, v2, a0", want: 0x0E2540D7},
+		{line: "vrsub.vi v1, v2, 5", want: 0x0E22B0D7},
+		{line: "vand.vv v1, v2, v3", want: 0x262180D7},
+		{line: "vand.vx v1, v2, a0", want: 0x262540D7},
+		{line: "vand.vi v1, v2, -1", want: 0x262FB0D7},
+		{line: "vor.vv v1, v2, v3", want: 0x2A2180D7},
+		{line: "vor.vx v1, v2, a0", want: 0x2A2540D7},
+		{line: "vor.vi v1, v2, 7", want: 0x2A23B0D7},
+		{line: "vxor.vv v1, v2, v3", want: 0x2E2180D7},
+		{line: "vxor.vx v1, v2, a0", want: 0x2E2540D7},
+		{line: "vxor.vi v1, v2, -1", want: 0x2E2FB0D7},
+		{line: "vminu.vv v1, v2, v3", want: 0x122180D7},
+		{line: "vminu.vx v1, v2, a0", want: 0x122540D7},
+		{line: "vmin.vv v1, v2, v3", want: 0x162180D7},
+		{line: "vmin.vx v1, v2, a0", want: 0x162540D7},
+		{line: "vmaxu.vv v1, v2, v3", want: 0x1A2180D7},
+		{line: "vmaxu.vx v1, v2, a0", want: 0x1A2540D7},
+		{line: "vmax.vv v1, v2, v3", want: 0x1E2180D7},
+		{line: "vmax.vx v1, v2, a0", want: 0x1E2540D7},
+		{line: "vsll.vv v1, v2, v3", want: 0x962180D7},
+		{line: "vsll.vx v1, v2, a0", want: 0x962540D7},
+		{line: "vsll.vi v1, v2, 31", want: 0x962FB0D7},
+		{line: "vsrl.vv v1, v2, v3", want: 0xA22180D7},
+		{line: "vsrl.vx v1, v2, a0", want: 0xA22540D7},
+		{line: "vsrl.vi v1, v2, 3", want: 0xA221B0D7},
+		{line: "vsra.vv v1, v2, v3", want: 0xA62180D7},
+		{line: "vsra.vx v1, v2, a0", want: 0xA62540D7},
+		{line: "vsra.vi v1, v2, 3", want: 0xA621B0D7},
+		{line: "vsetvli a0, a1, e128", wantErr: true},
+		{line: "vsetvli a0, a1, e32, m1, ma, ta", wantErr: true},
+		{line: "vsetvli a0, 4, e32", wantErr: true},
+		{line: "vsetivli a0, 32, e32", wantErr: true},
+		{line: "vsetivli a0, a1, e32", wantErr: true},
+		{line: "vle32.v v8, 4(a0)", wantErr: true},
+		{line: "vle32.v a0, (a0)", wantErr: true},
+		{line: "vadd.vv v0, v2, v3, v0.t", wantErr: true},
+		{line: "vadd.vv v1, v2, a0", wantErr: true},
+		{line: "vadd.vx v1, v2, v3", wantErr: true},
+		{line: "vadd.vi v1, v2, 16", wantErr: true},
+		{line: "vsll.vi v1, v2, 32", wantErr: true},
+		{line: "vsll.vi v1, v2, -1", wantErr: true},
+		{line: "vadd.vv v1, v2", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("AssembleLine(%q) = %#08x, want %#08x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_CacheManagement(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    uint32
+		wantErr bool
+	}{
+		{line: "cbo.clean (a0)", want: 0x0015200F},
+		{line: "cbo.clean 0(a0)", want: 0x0015200F},
+		{line: "cbo.flush (a1)", want: 0x0025A00F},
+		{line: "cbo.inval (a0)", want: 0x0005200F},
+		{line: "cbo.zero (t0)", want: 0x0042A00F},
+		{line: "prefetch.i 64(a0)", want: 0x04056013},
+		{line: "prefetch.r -32(a1)", want: 0xFE15E013},
+		{line: "prefetch.w (a2)", want: 0x00366013},
+		{line: "prefetch.w 2016(a2)", want: 0x7E366013},
+		{line: "prefetch.i -2048(a0)", want: 0x80056013},
+		{line: "czero.eqz a0, a1, a2", want: 0x0EC5D533},
+		{line: "czero.nez a0, a1, a2", want: 0x0EC5F533},
+		{line: "pause", want: 0x0100000F},
+		{line: "cbo.zero 4(a0)", wantErr: true},
+		{line: "cbo.clean a0", wantErr: true},
+		{line: "prefetch.r 33(a0)", wantErr: true},
+		{line: "prefetch.r 2048(a0)", wantErr: true},
+		{line: "czero.eqz a0, a1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("AssembleLine(%q) = %#08x, want %#08x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_CodeSize(t *testing.T) {
+	tests := []struct {
+		line    string
+		arch    Arch
+		want    uint16
+		wantErr bool
+	}{
+		// Zcb
+		{line: "c.lbu a0, 1(a1)", want: 0x81C8},
+		{line: "c.lbu a5, 2(a4)", want: 0x833C},
+		{line: "c.lhu a0, 2(a1)", want: 0x85A8},
+		{line: "c.lh a0, 2(a1)", want: 0x85E8},
+		{line: "c.sb a0, 3(a1)", want: 0x89E8},
+		{line: "c.sh a0, 2(a1)", want: 0x8DA8},
+		{line: "c.zext.b s0", want: 0x9C61},
+		{line: "c.sext.b s0", want: 0x9C65},
+		{line: "c.zext.h s0", want: 0x9C69},
+		{line: "c.sext.h s0", want: 0x9C6D},
+		{line: "c.not s0", want: 0x9C75},
+		{line: "c.mul s0, s1", want: 0x9C45},
+		{line: "c.zext.w s0", arch: RV64, want: 0x9C71},
+		{line: "c.zext.w s0", wantErr: true},
+		{line: "c.lbu a0, 4(a1)", wantErr: true},
+		{line: "c.lh a0, 1(a1)", wantErr: true},
+		{line: "c.sb a0, 0(sp)", wantErr: true},
+		{line: "c.zext.b a6", wantErr: true},
+		{line: "c.not s0, s1", wantErr: true},
+		// Zcmp
+		{line: "cm.push {ra}, -16", want: 0xB842},
+		{line: "cm.push {ra, s0-s3}, -32", want: 0xB882},
+		{line: "cm.push {ra, s0-s3}, -48", want: 0xB886},
+		{line: "cm.push {x1, x8-x9, x18-x20}, -32", want: 0xB892},
+		{line: "cm.push {ra, s0-s11}, -112", arch: RV64, want: 0xB8F2},
+		{line: "cm.push {ra,s0-s11},-160", arch: RV64, want: 0xB8FE},
+		{line: "cm.pop {ra, s0-s11}, 64", want: 0xBAF2},
+		{line: "cm.popret {ra, s0}, 16", want: 0xBE52},
+		{line: "cm.popretz {ra, s0-s1}, 32", want: 0xBC66},
+		{line: "cm.mvsa01 s1, s0", want: 0xACA2},
+		{line: "cm.mva01s s2, s7", want: 0xAD7E},
+		{line: "cm.push {ra, s0-s10}, -64", wantErr: true},
+		{line: "cm.push {ra, s1}, -16", wantErr: true},
+		{line: "cm.push {s0}, -16", wantErr: true},
+		{line: "cm.push {ra}, 16", wantErr: true},
+		{line: "cm.push {ra}, -80", wantErr: true},
+		{line: "cm.pop {ra, s0-s3}, 40", wantErr: true},
+		{line: "cm.push ra, -16", wantErr: true},
+		{line: "cm.mvsa01 s0, s0", wantErr: true},
+		{line: "cm.mva01s a0, s0", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arch.String()+" "+tt.line, func(t *testing.T) {
+			got, err := assembleLineHalfwords(tt.line, tt.arch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if len(got) != 1 || got[0] != tt.want {
+				t.Errorf("AssembleLine(%q) = %#04x, want %#04x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_RV32E(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []byte
+		wantErr bool
+	}{
+		{line: "add a0, a1, a5", want: []byte{0x33, 0x85, 0xF5, 0x00}},
+		{line: "lw s1, 4(x15)", want: []byte{0x83, 0xA4, 0x47, 0x00}},
+		{line: "fadd.s fa6, fa0, fa7", want: []byte{0x53, 0x78, 0x15, 0x01}},
+		{line: "c.jal 16", want: []byte{0x01, 0x28}},
+		{line: "cm.push {ra, s0-s1}, -16", want: []byte{0x62, 0xB8}},
+		{line: "add a6, a0, a1", wantErr: true},
+		{line: "addi a0, x16, 1", wantErr: true},
+		{line: "lw a0, 4(s2)", wantErr: true},
+		{line: "sw t3, 0(sp)", wantErr: true},
+		{line: "jalr t6", wantErr: true},
+		{line: "cm.push {ra, s0-s2}, -16", wantErr: true},
+		{line: "ld a0, 0(a1)", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			a := &Assembler{Arch: RV32E}
+			got, err := a.AssembleLine(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssembleLine(%q) = % x, want % x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_Insn(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []uint16
+		wantErr bool
+	}{
+		{line: ".insn r CUSTOM_0, 0, 0, a0, a1, a2", want: []uint16{0x850B, 0x00C5}},
+		{line: ".insn r 0x33, 0, 0, a0, a1, a2", want: []uint16{0x8533, 0x00C5}},
+		{line: ".insn r OP_FP, 7, 0, a0, a1, a2", want: []uint16{0xF553, 0x00C5}},
+		{line: ".insn r4 MADD, 0, 0, fa0, fa1, fa2, fa3", want: []uint16{0x8543, 0x68C5}},
+		{line: ".insn i LOAD, 2, a0, 8(a1)", want: []uint16{0xA503, 0x0085}},
+		{line: ".insn i 0x2b, 3, a0, a1, -12", want: []uint16{0xB52B, 0xFF45}},
+		{line: ".insn i 0x13, 1, a0, a1, 0x400", want: []uint16{0x9513, 0x4005}},
+		{line: ".insn s STORE, 2, a0, 8(a1)", want: []uint16{0xA423, 0x00A5}},
+		{line: ".insn b BRANCH, 0, a0, a1, 8", want: []uint16{0x0463, 0x00B5}},
+		{line: ".insn b 0x63, 1, a0, a1, -4096", want: []uint16{0x1063, 0x80B5}},
+		{line: ".insn u LUI, a0, 0x12345", want: []uint16{0x5537, 0x1234}},
+		{line: ".insn u 0x17, a0, 0xfffff", want: []uint16{0xF517, 0xFFFF}},
+		{line: ".insn j JAL, ra, 16", want: []uint16{0x00EF, 0x0100}},
+		{line: ".insn 0x12345677", want: []uint16{0x5677, 0x1234}},
+		{line: ".insn 4, 0x0000000b", want: []uint16{0x000B, 0x0000}},
+		{line: ".insn 0x0001", want: []uint16{0x0001}},
+		{line: ".insn 2, 0x8082", want: []uint16{0x8082}},
+		{line: ".insn", wantErr: true},
+		{line: ".insn x 1", wantErr: true},
+		{line: ".insn 2, 0x0000000b", wantErr: true},
+		{line: ".insn 0x10000", wantErr: true},
+		{line: ".insn r CUSTOM_9, 0, 0, a0, a1, a2", wantErr: true},
+		{line: ".insn r 0x08, 0, 0, a0, a1, a2", wantErr: true},
+		{line: ".insn r CUSTOM_0, 8, 0, a0, a1, a2", wantErr: true},
+		{line: ".insn r CUSTOM_0, 0, 128, a0, a1, a2", wantErr: true},
+		{line: ".insn r CUSTOM_0, 0, 0, a0, a1", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineHalfwords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssembleLine(%q) = %#04x, want %#04x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_Pseudos(t *testing.T) {
+	tests := []struct {
+		march   string
+		line    string
+		want    []uint32
+		wantErr string
+	}{
+		{line: "neg a0, a1", want: []uint32{0x40B00533}},
+		{line: "not a0, a1", want: []uint32{0xFFF5C513}},
+		{line: "seqz a0, a1", want: []uint32{0x0015B513}},
+		{line: "snez a0, a1", want: []uint32{0x00B03533}},
+		{line: "sltz a0, a1", want: []uint32{0x0005A533}},
+		{line: "sgtz a0, a1", want: []uint32{0x00B02533}},
+		{line: "beqz a0, 8", want: []uint32{0x00050463}},
+		{line: "bgt a0, a1, 8", want: []uint32{0x00A5C463}},
+		{line: "jalr a0", want: []uint32{0x000500E7}},
+		{line: "zext.b a0, a1", want: []uint32{0x0FF5F513}},
+		{line: "frcsr a0", want: []uint32{0x00302573}},
+		{line: "fence", want: []uint32{0x0FF0000F}},
+		{line: "sext.b a0, a1", want: []uint32{0x01859513, 0x41855513}},
+		{march: "rv32i", line: "sext.b a0, a1", want: []uint32{0x01859513, 0x41855513}},
+		{march: "rv32i_zbb", line: "sext.b a0, a1", want: []uint32{0x60459513}},
+		{line: "zext.h a0, a1", want: []uint32{0x01059513, 0x01055513}},
+		{march: "rv64i_zba", line: "zext.w a0, a1", want: []uint32{0x0805853B}},
+		{march: "rv64i_zbb", line: "zext.w a0, a1", want: []uint32{0x02059513, 0x02055513}},
+		{line: "mv a0", wantErr: "instruction 'mv' expects 2 operands, got 1"},
+		{line: "beqz a0, a1", wantErr: "operand 2 of 'beqz' expects immediate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.march+" "+tt.line, func(t *testing.T) {
+			a := &Assembler{March: tt.march}
+			code, err := a.AssembleLine(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("AssembleLine(%q) error = %v, want %q", tt.line, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AssembleLine(%q) error = %v", tt.line, err)
+			}
+			var got []uint32
+			for i := 0; i+4 <= len(code); i += 4 {
+				got = append(got, binary.LittleEndian.Uint32(code[i:]))
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_BranchRelaxation(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    []uint32
+		wantErr bool
+	}{
+		{"beq a0, a1, 4094", []uint32{0x7EB50FE3}, false},
+		{"beq a0, a1, 8192", []uint32{0x00B51463, 0x7FD0106F}, false},
+		{"jal ra, 2000000", []uint32{0x001E8097, 0x480080E7}, false},
+		{"jal t0, -2000000", []uint32{0xFFE18297, 0xB80282E7}, false},
+		// a plain jump has no register to build the address in, tail takes t1 explicitly
+		{"jal x0, 2000000", nil, true},
+		{"j 2000000", nil, true},
+		// the jal over which the branch skips is out of range as well
+		{"beq a0, a1, 2000000", nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			got, err := assembleLineWords(tt.line)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine(%q) error = %v, wantErr %v", tt.line, err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssembleLine(%q) = %08X, want %08X", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_Equates(t *testing.T) {
+	a := &Assembler{}
+	for _, line := range []string{".equ FOO, 5", ".set BAR, FOO"} {
+		if code, err := a.AssembleLine(line); err != nil || len(code) != 0 {
+			t.Fatalf("AssembleLine(%q) = % x, %v, want no code", line, code, err)
+		}
+	}
+	got, err := a.AssembleLine("addi a0, x0, BAR")
+	if err != nil {
+		t.Fatalf("AssembleLine() error = %v", err)
+	}
+	if want := []byte{0x13, 0x05, 0x50, 0x00}; !reflect.DeepEqual(got, want) {
+		t.Errorf("AssembleLine() = % x, want % x", got, want)
+	}
+	if _, err := a.AssembleLine(".equ BAZ"); err == nil || !strings.Contains(err.Error(), "expected a name and a value") {
+		t.Errorf("AssembleLine(\".equ BAZ\") error = %v, want a missing value error", err)
+	}
+}
@@ -2,7 +2,9 @@ package assembler
 
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -15,10 +17,60 @@ type Compilation struct {
 	constantCount               int
 	stringCount                 int //= 8
 	callbackInstructions        [][2]interface{}
+	arch                        Arch
+	isa                         *ISA           // -march extensions, recorded in .riscv.attributes
+	relayout                    bool           // a compressed instruction grew back to 32 bits or a branch was relaxed
+	hasCompressed               bool           // instructions were shrunk under .option rvc
+	relaxations                 int            // branches rewritten to reach their target, numbering their labels
+	pcrelHi                     map[int]*Token // the %pcrel_hi symbol of each auipc by position, for the %pcrel_lo pairing with it
 }
 
 func (c *Compilation) compile(token *Token) (Program, error) {
+	layoutStart := c.instructionCountCompilation
+	prog, err := c.layout(token)
+	// a compressed instruction whose operands stopped fitting grows back to 32 bits and
+	// a branch out of range grows into a longer sequence, moving the labels after them,
+	// so the layout is redone until it is stable
+	for err == nil {
+		// strings are placed after the code size counted while parsing,
+		// which could not know the instructions that end up compressed
+		codeSize := c.instructionCountCompilation - layoutStart
+		if (c.hasCompressed || c.relaxations > 0) && codeSize != c.instructionCount {
+			c.instructionCount = codeSize
+			c.relayout = true
+		}
+		if !c.relayout {
+			break
+		}
+		c.instructionCountCompilation = layoutStart
+		c.callbackInstructions = nil
+		clear(c.labelPositions)
+		prog, err = c.layout(token)
+	}
+	if err != nil {
+		return Program{}, err
+	}
+	if prog.compilationVariables.compilationEntryPoint != "" {
+		val, _ := prog.compilationVariables.labelPositions[prog.compilationVariables.compilationEntryPoint]
+		var bts = make([]byte, 4)
+		binary.LittleEndian.PutUint32(bts, uint32(val))
+		prog.entrypoint = [4]byte(bts)
+	} else {
+		val, _ := prog.compilationVariables.labelPositions["main"]
+		var bts = make([]byte, 4)
+		binary.LittleEndian.PutUint32(bts, uint32(val))
+		prog.entrypoint = [4]byte(bts)
+	}
+	return prog, nil
+}
+
+// layout places every token and encodes the instructions once the labels are known
+func (c *Compilation) layout(token *Token) (Program, error) {
+	c.relayout = false
+	c.pcrelHi = map[int]*Token{}
 	prog := Program{}
+	prog.arch = c.arch
+	prog.isa = c.isa
 	prog.compilationVariables = c
 	prog.strings = append(prog.strings, uint8(00))
 	err := prog.recursiveCompilation(token)
@@ -37,17 +89,6 @@ func (c *Compilation) compile(token *Token) (Program, error) {
 	if len(prog.strings) == 1 {
 		prog.strings = nil
 	}
-	if prog.compilationVariables.compilationEntryPoint != "" {
-		val, _ := prog.compilationVariables.labelPositions[prog.compilationVariables.compilationEntryPoint]
-		var bts = make([]byte, 4)
-		binary.LittleEndian.PutUint32(bts, uint32(val))
-		prog.entrypoint = [4]byte(bts)
-	} else {
-		val, _ := prog.compilationVariables.labelPositions["main"]
-		var bts = make([]byte, 4)
-		binary.LittleEndian.PutUint32(bts, uint32(val))
-		prog.entrypoint = [4]byte(bts)
-	}
 	return prog, nil
 }
 
@@ -95,7 +136,7 @@ func (p *Program) recursiveCompilation(token *Token) error {
 			// Handle multiple comma-separated values
 			values := splitValues(token.children[1].value)
 			for _, valueStr := range values {
-				val, err := parseIntValue(valueStr)
+				val, err := parseInt64Value(valueStr)
 				if err != nil {
 					return err
 				}
@@ -148,7 +189,7 @@ func (p *Program) recursiveCompilation(token *Token) error {
 			// Handle multiple comma-separated values
 			values := splitValues(token.children[1].value)
 			for _, valueStr := range values {
-				val, err := parseIntValue(valueStr)
+				val, err := parseInt64Value(valueStr)
 				if err != nil {
 					return err
 				}
@@ -168,7 +209,7 @@ func (p *Program) recursiveCompilation(token *Token) error {
 	// case constant:
 	// 	p.compilationVariables.labelPositions[strings.Replace(token.value, ":", "", 1)] = p.compilationVariables.instructionCountCompilation + p.compilationVariables.variableCount + len(p.constants)
 	// 	p.callDescendants(token)
-	case globalLabel:
+	case globalLabel, localLabel:
 		p.compilationVariables.labelPositions[strings.Replace(token.value, ":", "", 1)] = p.compilationVariables.instructionCountCompilation
 		fallthrough
 	case section:
@@ -179,17 +220,49 @@ func (p *Program) recursiveCompilation(token *Token) error {
 			return err
 		}
 	case instruction:
+		if len(token.relaxed) > 0 {
+			return p.callDescendants(&Token{children: token.relaxed}, p.recursiveCompilation)
+		}
+		if symbol := pcrelHiSymbol(token); symbol != nil {
+			p.compilationVariables.pcrelHi[p.compilationVariables.instructionCountCompilation] = symbol
+		}
+		size := token.opPair.opType.size()
+		if len(token.compressed) > 0 {
+			size = 2
+			p.compilationVariables.hasCompressed = true
+		}
 		p.compilationVariables.callbackInstructions = append(p.compilationVariables.callbackInstructions,
 			[2]interface{}{func(relativeInstrCount int) error {
+				if len(token.compressed) > 0 {
+					for _, form := range token.compressed {
+						if val, err := p.InstructionToBinary(form, relativeInstrCount); err == nil {
+							p.machinecode = binary.LittleEndian.AppendUint16(p.machinecode, uint16(val))
+							return nil
+						}
+					}
+					// no 16 bit form fits at this position, keep the 32 bit encoding
+					token.compressed = nil
+					p.compilationVariables.relayout = true
+					return nil
+				}
 				val, err := p.InstructionToBinary(token, relativeInstrCount)
+				if errors.Is(err, errOutOfRange) && p.compilationVariables.relax(token) {
+					// the longer sequence replacing it moves the labels after it
+					p.compilationVariables.relayout = true
+					return nil
+				}
 				if err != nil {
 					return err
 				}
-				p.machinecode = binary.LittleEndian.AppendUint32(p.machinecode, val)
+				if token.opPair.opType.size() == 2 {
+					p.machinecode = binary.LittleEndian.AppendUint16(p.machinecode, uint16(val))
+				} else {
+					p.machinecode = binary.LittleEndian.AppendUint32(p.machinecode, val)
+				}
 				return nil
 			},
 				p.compilationVariables.instructionCountCompilation})
-		p.compilationVariables.instructionCountCompilation += 4
+		p.compilationVariables.instructionCountCompilation += size
 	case entrypoint:
 		if token.value == ".globl" {
 			p.compilationVariables.compilationEntryPoint = token.children[0].value
@@ -200,6 +273,84 @@ endGoTo:
 	return nil
 }
 
+// pcrelHiSymbol returns the symbol of the %pcrel_hi(symbol) operand of an auipc, nil for
+// the other instructions
+func pcrelHiSymbol(token *Token) *Token {
+	if token.value != "auipc" || len(token.children) != 2 {
+		return nil
+	}
+	operand := token.children[1]
+	if operand.tokenType != complexValue || len(operand.children) != 2 || operand.children[0].value != "%pcrel_hi" {
+		return nil
+	}
+	return operand.children[1]
+}
+
+// pcrelLoTarget returns the position of the auipc a %pcrel_lo(symbol) at pos pairs with and
+// the symbol its %pcrel_hi addresses: the auipc right before pos when it addresses the same
+// symbol, or else the auipc symbol labels, the way GNU as pairs them
+func (c *Compilation) pcrelLoTarget(symbol *Token, pos int) (int, *Token, error) {
+	if hi, ok := c.pcrelHi[pos-4]; ok && hi.value == symbol.value {
+		return pos - 4, hi, nil
+	}
+	if at, ok := c.labelPositions[symbol.value]; ok {
+		if hi, ok := c.pcrelHi[at]; ok {
+			return at, hi, nil
+		}
+	}
+	return 0, nil, fmt.Errorf("%%pcrel_lo(%s): no auipc with %%pcrel_hi(%s) right before it or at the label %s", symbol.value, symbol.value, symbol.value)
+}
+
+// invertedBranches gives the branch taken when the condition of the other one is false
+var invertedBranches = map[string]string{
+	"beq": "bne", "bne": "beq", "blt": "bge", "bge": "blt", "bltu": "bgeu", "bgeu": "bltu",
+}
+
+// relax replaces a branch whose target is out of range by an inverted branch over a
+// jal to the target, and a jal by an auipc and jalr pair through rd, it reports false for
+// the instructions it cannot rewrite, a plain jump included as it has no register to spare
+func (c *Compilation) relax(token *Token) bool {
+	switch {
+	case token.opPair.opType == B && invertedBranches[token.value] != "" && len(token.children) == 3:
+		skip := fmt.Sprintf(".Lrelax%d", c.relaxations)
+		inverted := invertedBranches[token.value]
+		branch := NewToken(instruction, inverted, token.parent, &OpPair{B, []byte{token.opPair.opByte[0], token.opPair.opByte[1] ^ 1}})
+		branch.children = []*Token{token.children[0], token.children[1], NewToken(constantValue, skip, branch)}
+		jal := NewToken(instruction, "jal", token.parent, pairOf("jal"))
+		target := token.children[2]
+		if target.tokenType == literal {
+			// an offset from the branch, the jal comes 4 bytes later
+			val, _ := parseIntValue(target.value)
+			target = NewToken(literal, strconv.Itoa(val-4), jal)
+		}
+		jal.children = []*Token{NewToken(register, "x0", jal), target}
+		token.relaxed = []*Token{branch, jal, NewToken(localLabel, skip+":", token.parent)}
+	case token.opPair.opType == J && token.value == "jal" && len(token.children) == 2:
+		rd := token.children[0].value
+		if num, _ := token.children[0].getRegisterNumericValue(); num == 0 {
+			return false
+		}
+		target := token.children[1].value
+		auipc := NewToken(instruction, "auipc", token.parent, pairOf("auipc"))
+		jalr := NewToken(instruction, "jalr", token.parent, pairOf("jalr"))
+		if LexUType([]string{rd, "%pcrel_hi(" + target + ")"}, auipc) != nil ||
+			LexIType([]string{rd, rd, "%pcrel_lo(" + target + ")"}, jalr) != nil {
+			return false
+		}
+		token.relaxed = []*Token{auipc, jalr}
+	default:
+		return false
+	}
+	c.relaxations++
+	return true
+}
+
+// pairOf returns a copy of the OpPair of the standard instruction name
+func pairOf(name string) *OpPair {
+	pair := instructionToOpType[name]
+	return &pair
+}
+
 func (p *Program) callDescendants(token *Token, recursionFn func(*Token) error) error {
 	for _, child := range token.children {
 		err := recursionFn(child)
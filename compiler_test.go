@@ -1,6 +1,8 @@
 package assembler
 
 import (
+	"encoding/hex"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
@@ -121,7 +123,10 @@ empty_string: .asciz ""
 			}
 			defer file.Close()
 
-			lines := Preprocess(file)
+			lines, err := Preprocess(file)
+			if err != nil {
+				t.Fatalf("Preprocess() error = %v", err)
+			}
 
 			actualParent := asm.Token
 			for _, line := range lines {
@@ -271,7 +276,10 @@ global_label:
 			}
 			defer file.Close()
 
-			lines := Preprocess(file)
+			lines, err := Preprocess(file)
+			if err != nil {
+				t.Fatalf("Preprocess() error = %v", err)
+			}
 			for _, line := range lines {
 				lineParts := splitLine(line)
 				if len(lineParts) == 0 {
@@ -287,6 +295,8 @@ global_label:
 			p := &Program{
 				strings: []byte{0}, // Initial empty strings section
 			}
+			p.compilationVariables = &Compilation{}
+			p.compilationVariables.labelPositions = map[string]int{}
 
 			// Perform recursive compilation on the root token
 			p.recursiveCompilation(asm.Token)
@@ -389,6 +399,200 @@ start:
 	}
 }
 
+// compileSource assembles source from a temporary file and compiles its tokens
+func compileSource(t *testing.T, source string) (Program, error) {
+	t.Helper()
+	tempFile, err := createTempAssemblyFile(source)
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer cleanupTempFiles(tempFile)
+
+	asm := Assembler{}
+	if err := asm.Assemble(tempFile, ""); err != nil {
+		return Program{}, fmt.Errorf("Assemble error: %w", err)
+	}
+	c := Compilation{labelPositions: map[string]int{}}
+	prog, err := c.compile(asm.Token)
+	if err != nil {
+		return Program{}, fmt.Errorf("Compile error: %w", err)
+	}
+	return prog, nil
+}
+
+func TestCompile_MixedInstructionSizes(t *testing.T) {
+	source := `
+.text
+main:
+  c.li a0, 5
+loop:
+  addi a0, a0, -1
+  c.bnez a0, loop
+  c.j end
+  add x0, x0, x0
+end:
+  c.nop
+`
+	prog, err := compileSource(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []byte{
+		0x15, 0x45, // 0x0: c.li a0, 5
+		0x13, 0x05, 0xF5, 0xFF, // 0x2: addi a0, a0, -1
+		0x75, 0xFD, // 0x6: c.bnez a0, loop (-4)
+		0x19, 0xA0, // 0x8: c.j end (+6)
+		0x33, 0x00, 0x00, 0x00, // 0xA: add x0, x0, x0
+		0x01, 0x00, // 0xE: c.nop
+	}
+	if !reflect.DeepEqual(prog.machinecode, want) {
+		t.Errorf("compile() machinecode = % x, want % x", prog.machinecode, want)
+	}
+	if pos := prog.compilationVariables.labelPositions["end"]; pos != 0xE {
+		t.Errorf("label end = %#x, want 0xe", pos)
+	}
+}
+
+func TestCompile_CompressionRelayout(t *testing.T) {
+	// 66 uncompressible instructions put loop 266 bytes behind the bne,
+	// past the reach of c.bnez, so it grows back and moves end by 2 bytes
+	source := ".text\n.option rvc\nmain:\nloop:\n  addi a0, a0, -1\n" +
+		strings.Repeat("  addi a0, a1, 1\n", 66) +
+		"  bne a0, x0, loop\n  jal x0, main\nend:\n  ret\n" +
+		".data\nmsg: .string \"hi\"\n"
+	prog, err := compileSource(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := prog.machinecode
+	if len(code) != 274 {
+		t.Fatalf("compile() machinecode length = %d, want 274", len(code))
+	}
+	if got := hex.EncodeToString(code[266:]); got != "e31b05eecdbd8280" {
+		t.Errorf("compile() tail = %s, want e31b05eecdbd8280", got)
+	}
+	if pos := prog.compilationVariables.labelPositions["end"]; pos != 272 {
+		t.Errorf("label end = %d, want 272", pos)
+	}
+	if pos := prog.compilationVariables.labelPositions["msg"]; pos != len(code)+1 {
+		t.Errorf("label msg = %d, want %d", pos, len(code)+1)
+	}
+}
+
+func TestCompile_FarCall(t *testing.T) {
+	// far sits 0x1c00 bytes after main, so every %pcrel_lo is negative and
+	// %pcrel_hi rounds up to 2
+	source := ".text\nmain:\n  call far\n  tail far\n  call t0, far\n" +
+		strings.Repeat("  nop\n", 1786) +
+		"far:\n  ret\n"
+	prog, err := compileSource(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pos := prog.compilationVariables.labelPositions["far"]; pos != 0x1c00 {
+		t.Fatalf("label far = %#x, want 0x1c00", pos)
+	}
+	want := "97200000e78000c017230000670083bf97220000e78202bf"
+	if got := hex.EncodeToString(prog.machinecode[:24]); got != want {
+		t.Errorf("compile() head = %s, want %s", got, want)
+	}
+}
+
+func TestCompile_LoadImmediate(t *testing.T) {
+	// far sits at 0x800, the sign bit of %lo, so its %hi rounds up like SIZE does
+	source := ".equ SIZE, 0x800\n.text\nmain:\n  li a0, 0x12345FFF\n  li a1, SIZE\n  li a2, far\n" +
+		strings.Repeat("  nop\n", 506) +
+		"far:\n  ret\n"
+	prog, err := compileSource(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pos := prog.compilationVariables.labelPositions["far"]; pos != 0x800 {
+		t.Fatalf("label far = %#x, want 0x800", pos)
+	}
+	want := "376534121305f5ffb7150000938505803716000013060680"
+	if got := hex.EncodeToString(prog.machinecode[:24]); got != want {
+		t.Errorf("compile() head = %s, want %s", got, want)
+	}
+}
+
+func TestCompile_SymbolLoadStore(t *testing.T) {
+	// counter sits at 0x1000, each auipc is 8 bytes closer to it than the one before
+	source := ".text\nmain:\n  lw a0, counter\n  sw a0, counter, t0\n  flw fa0, counter, t1\n" +
+		"  fsd fa0, counter, t2\n  lbu a1, counter\n" +
+		strings.Repeat("  nop\n", 1014) +
+		"counter:\n  ret\n"
+	prog, err := compileSource(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pos := prog.compilationVariables.labelPositions["counter"]; pos != 0x1000 {
+		t.Fatalf("label counter = %#x, want 0x1000", pos)
+	}
+	want := "17150000032505009712000023aca2fe17130000072503ff9713000027b4a3fe9715000083c505fe"
+	if got := hex.EncodeToString(prog.machinecode[:40]); got != want {
+		t.Errorf("compile() head = %s, want %s", got, want)
+	}
+}
+
+func TestCompile_PcrelLo(t *testing.T) {
+	// data sits 12 bytes after the auipc, which is not the instruction before the lw
+	tests := []struct {
+		name    string
+		access  string
+		want    string
+		wantErr bool
+	}{
+		{"label of the auipc", "lw a0, %pcrel_lo(pair)(a0)", "17050000938505000325c500", false},
+		{"symbol with the auipc not right before", "lw a0, %pcrel_lo(data)(a0)", "", true},
+		{"label not at an auipc", "lw a0, %pcrel_lo(main)(a0)", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := ".text\nmain:\n  addi a1, a1, 0\npair:\n  auipc a0, %pcrel_hi(data)\n  addi a1, a1, 0\n  " +
+				tt.access + "\ndata:\n  ret\n"
+			prog, err := compileSource(t, source)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("compileSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := hex.EncodeToString(prog.machinecode); !tt.wantErr && got[8:32] != tt.want {
+				t.Errorf("compile() = %s, want %s after the first instruction", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompile_BranchRelaxation(t *testing.T) {
+	// 1100 nops put far out of the reach of a branch in both directions, each branch
+	// grows to an inverted branch over a jal, moving far by 4 bytes
+	source := ".text\nmain:\n  beq a0, a1, far\n" +
+		strings.Repeat("  nop\n", 1100) +
+		"far:\n  bne a0, a1, main\n  ret\n"
+	prog, err := compileSource(t, source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := prog.machinecode
+	if len(code) != 4420 {
+		t.Fatalf("compile() machinecode length = %d, want 4420", len(code))
+	}
+	if pos := prog.compilationVariables.labelPositions["far"]; pos != 4408 {
+		t.Errorf("label far = %d, want 4408", pos)
+	}
+	if got := hex.EncodeToString(code[:8]); got != "6314b5006f104013" {
+		t.Errorf("compile() head = %s, want 6314b5006f104013", got)
+	}
+	if got := hex.EncodeToString(code[4408:]); got != "6304b5006fe05fec67800000" {
+		t.Errorf("compile() tail = %s, want 6304b5006fe05fec67800000", got)
+	}
+}
+
 func TestProgramCallDescendants(t *testing.T) {
 	// Create an assembly source with a simple structure
 	assemblySource := `
@@ -417,7 +621,10 @@ label2:
 	}
 	defer file.Close()
 
-	lines := Preprocess(file)
+	lines, err := Preprocess(file)
+	if err != nil {
+		t.Fatalf("Preprocess() error = %v", err)
+	}
 
 	actualParent := asm.Token
 	for _, line := range lines {
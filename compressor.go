@@ -0,0 +1,271 @@
+package assembler
+
+// compressedForm is a 16 bit instruction equivalent to a 32 bit one
+type compressedForm struct {
+	name     string
+	operands []*Token
+}
+
+// CompressibleInstructions lists for each instruction the compressed forms it can take,
+// a form whose operands do not fit (register outside x8-x15, immediate out of range...)
+// is rejected by its encoder and the next form or the 32 bit encoding is used instead
+var CompressibleInstructions = map[string]func([]*Token) []compressedForm{
+	"addi":   compressADDI,
+	"addiw":  compressSameDestination("c.addiw"),
+	"lui":    compressLUI,
+	"slli":   compressShift("c.slli"),
+	"srli":   compressShift("c.srli"),
+	"srai":   compressShift("c.srai"),
+	"andi":   compressSameDestination("c.andi"),
+	"add":    compressADD,
+	"sub":    compressArithmetic("c.sub", false),
+	"xor":    compressArithmetic("c.xor", true),
+	"or":     compressArithmetic("c.or", true),
+	"and":    compressArithmetic("c.and", true),
+	"addw":   compressArithmetic("c.addw", true),
+	"subw":   compressArithmetic("c.subw", false),
+	"lw":     compressMemory("c.lwsp", "c.lw"),
+	"ld":     compressMemory("c.ldsp", "c.ld"),
+	"flw":    compressMemory("c.flwsp", "c.flw"),
+	"fld":    compressMemory("c.fldsp", "c.fld"),
+	"sw":     compressMemory("c.swsp", "c.sw"),
+	"sd":     compressMemory("c.sdsp", "c.sd"),
+	"fsw":    compressMemory("c.fswsp", "c.fsw"),
+	"fsd":    compressMemory("c.fsdsp", "c.fsd"),
+	"jal":    compressJAL,
+	"jalr":   compressJALR,
+	"beq":    compressBranch("c.beqz"),
+	"bne":    compressBranch("c.bnez"),
+	"ebreak": compressEBREAK,
+}
+
+// compressedForms returns the 16 bit tokens t can be replaced with on the target Arch
+func (a *Assembler) compressedForms(t *Token) []*Token {
+	rule, ok := CompressibleInstructions[t.value]
+	if !ok {
+		return nil
+	}
+	var forms []*Token
+	for _, form := range rule(t.children) {
+		pair, err := a.lookupInstruction(form.name)
+		if err != nil {
+			// c.jal, c.flw... only exist on one of the base ISAs
+			continue
+		}
+		tk := NewToken(instruction, form.name, t.parent, &pair)
+		tk.children = form.operands
+		forms = append(forms, tk)
+	}
+	return forms
+}
+
+// registerOperand returns the register number of tok or -1 when it is not a register
+func registerOperand(tok *Token) int {
+	reg, err := tok.getRegisterNumericValue()
+	if err != nil {
+		return -1
+	}
+	return reg
+}
+
+// literalOperand reports whether tok is a plain number and if it is zero
+func literalOperand(tok *Token) (isLiteral bool, isZero bool) {
+	if tok.tokenType != literal {
+		return false, false
+	}
+	val, err := parseIntValue(tok.value)
+	return err == nil, err == nil && val == 0
+}
+
+func compressADDI(ops []*Token) []compressedForm {
+	if len(ops) != 3 {
+		return nil
+	}
+	isLiteral, isZero := literalOperand(ops[2])
+	if !isLiteral {
+		return nil
+	}
+	rd, rs1 := registerOperand(ops[0]), registerOperand(ops[1])
+	var forms []compressedForm
+	if rd == 0 && rs1 == 0 && isZero {
+		forms = append(forms, compressedForm{"c.nop", nil})
+	}
+	if rd > 0 && rd == rs1 && !isZero {
+		forms = append(forms, compressedForm{"c.addi", []*Token{ops[0], ops[2]}})
+	}
+	if rd == 2 && rs1 == 2 {
+		forms = append(forms, compressedForm{"c.addi16sp", []*Token{ops[0], ops[2]}})
+	} else if rs1 == 2 {
+		forms = append(forms, compressedForm{"c.addi4spn", ops})
+	}
+	if rd > 0 && rs1 == 0 {
+		forms = append(forms, compressedForm{"c.li", []*Token{ops[0], ops[2]}})
+	}
+	if rd > 0 && rs1 > 0 && isZero {
+		forms = append(forms, compressedForm{"c.mv", []*Token{ops[0], ops[1]}})
+	}
+	return forms
+}
+
+func compressLUI(ops []*Token) []compressedForm {
+	if len(ops) != 2 {
+		return nil
+	}
+	if isLiteral, _ := literalOperand(ops[1]); !isLiteral {
+		return nil
+	}
+	return []compressedForm{{"c.lui", ops}}
+}
+
+// compressSameDestination handles rd, rd, imm instructions becoming c.x rd, imm
+func compressSameDestination(name string) func([]*Token) []compressedForm {
+	return func(ops []*Token) []compressedForm {
+		if len(ops) != 3 {
+			return nil
+		}
+		if isLiteral, _ := literalOperand(ops[2]); !isLiteral {
+			return nil
+		}
+		rd := registerOperand(ops[0])
+		if rd <= 0 || rd != registerOperand(ops[1]) {
+			return nil
+		}
+		return []compressedForm{{name, []*Token{ops[0], ops[2]}}}
+	}
+}
+
+// compressShift is compressSameDestination without the shift by 0 hints
+func compressShift(name string) func([]*Token) []compressedForm {
+	sameDestination := compressSameDestination(name)
+	return func(ops []*Token) []compressedForm {
+		if len(ops) == 3 {
+			if _, isZero := literalOperand(ops[2]); isZero {
+				return nil
+			}
+		}
+		return sameDestination(ops)
+	}
+}
+
+func compressADD(ops []*Token) []compressedForm {
+	if len(ops) != 3 {
+		return nil
+	}
+	rd, rs1, rs2 := registerOperand(ops[0]), registerOperand(ops[1]), registerOperand(ops[2])
+	if rd <= 0 || rs1 < 0 || rs2 < 0 {
+		return nil
+	}
+	switch {
+	case rs1 == 0:
+		return []compressedForm{{"c.mv", []*Token{ops[0], ops[2]}}}
+	case rs2 == 0:
+		return []compressedForm{{"c.mv", []*Token{ops[0], ops[1]}}}
+	case rd == rs1:
+		return []compressedForm{{"c.add", []*Token{ops[0], ops[2]}}}
+	case rd == rs2:
+		return []compressedForm{{"c.add", []*Token{ops[0], ops[1]}}}
+	}
+	return nil
+}
+
+// compressArithmetic handles rd, rd, rs2 instructions, commutative ones also accept rd, rs1, rd
+func compressArithmetic(name string, commutative bool) func([]*Token) []compressedForm {
+	return func(ops []*Token) []compressedForm {
+		if len(ops) != 3 {
+			return nil
+		}
+		rd, rs1, rs2 := registerOperand(ops[0]), registerOperand(ops[1]), registerOperand(ops[2])
+		if rd < 0 || rs1 < 0 || rs2 < 0 {
+			return nil
+		}
+		if rd == rs1 {
+			return []compressedForm{{name, []*Token{ops[0], ops[2]}}}
+		}
+		if commutative && rd == rs2 {
+			return []compressedForm{{name, []*Token{ops[0], ops[1]}}}
+		}
+		return nil
+	}
+}
+
+// compressMemory handles the loads and stores, spName is the form addressing from sp
+func compressMemory(spName string, name string) func([]*Token) []compressedForm {
+	return func(ops []*Token) []compressedForm {
+		if len(ops) != 2 || ops[1].tokenType != complexValue || len(ops[1].children) != 2 {
+			return nil
+		}
+		if isLiteral, _ := literalOperand(ops[1].children[0]); !isLiteral {
+			return nil
+		}
+		reg, base := registerOperand(ops[0]), registerOperand(ops[1].children[1])
+		if reg < 0 || base < 0 {
+			return nil
+		}
+		if base == 2 {
+			// x0 is reserved as the destination of the sp relative loads
+			if reg == 0 && (spName == "c.lwsp" || spName == "c.ldsp") {
+				return nil
+			}
+			return []compressedForm{{spName, ops}}
+		}
+		return []compressedForm{{name, ops}}
+	}
+}
+
+func compressJAL(ops []*Token) []compressedForm {
+	if len(ops) != 2 || ops[1].tokenType == register || ops[1].tokenType == complexValue {
+		return nil
+	}
+	switch registerOperand(ops[0]) {
+	case 0:
+		return []compressedForm{{"c.j", ops[1:]}}
+	case 1:
+		return []compressedForm{{"c.jal", ops[1:]}}
+	}
+	return nil
+}
+
+func compressJALR(ops []*Token) []compressedForm {
+	var rs1 *Token
+	switch {
+	case len(ops) == 2 && ops[1].tokenType == complexValue && len(ops[1].children) == 2:
+		if _, isZero := literalOperand(ops[1].children[0]); !isZero {
+			return nil
+		}
+		rs1 = ops[1].children[1]
+	case len(ops) == 3:
+		if _, isZero := literalOperand(ops[2]); !isZero {
+			return nil
+		}
+		rs1 = ops[1]
+	default:
+		return nil
+	}
+	if registerOperand(rs1) <= 0 {
+		return nil
+	}
+	switch registerOperand(ops[0]) {
+	case 0:
+		return []compressedForm{{"c.jr", []*Token{rs1}}}
+	case 1:
+		return []compressedForm{{"c.jalr", []*Token{rs1}}}
+	}
+	return nil
+}
+
+// compressBranch handles the comparisons of rs1 against x0
+func compressBranch(name string) func([]*Token) []compressedForm {
+	return func(ops []*Token) []compressedForm {
+		if len(ops) != 3 || ops[2].tokenType == register || registerOperand(ops[1]) != 0 {
+			return nil
+		}
+		return []compressedForm{{name, []*Token{ops[0], ops[2]}}}
+	}
+}
+
+func compressEBREAK(ops []*Token) []compressedForm {
+	if len(ops) != 0 {
+		return nil
+	}
+	return []compressedForm{{"c.ebreak", nil}}
+}
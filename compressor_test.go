@@ -0,0 +1,127 @@
+package assembler
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestAssembler_AssembleLine_Compression(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"addi sp, sp, -16", "4111"},
+		{"addi sp, sp, -64", "3971"},
+		{"addi a0, sp, 16", "0808"},
+		{"addi a0, x0, 5", "1545"},
+		{"addi a0, a1, 0", "2e85"},
+		{"addi x0, x0, 0", "0100"},
+		{"addi a0, a0, 0", "2a85"},
+		{"addi a0, a1, 1", "13851500"},
+		{"lui a0, 1", "0565"},
+		{"lui sp, 1", "37110000"},
+		{"slli a0, a0, 3", "0e05"},
+		{"slli a0, a0, 0", "13150500"},
+		{"srai s0, s0, 2", "0984"},
+		{"andi a0, a0, 7", "1d89"},
+		{"add a0, a0, a1", "2e95"},
+		{"add a2, a3, a2", "3696"},
+		{"add a0, x0, a1", "2e85"},
+		{"sub s0, s0, a0", "098c"},
+		{"sub s0, a0, s0", "33048540"},
+		{"or s1, a5, s1", "dd8c"},
+		{"lw ra, 12(sp)", "b240"},
+		{"lw a0, 8(a1)", "8845"},
+		{"lw t0, 8(t1)", "83228300"},
+		{"lw a0, 128(a1)", "03a50508"},
+		{"sw ra, 12(sp)", "06c6"},
+		{"sw a0, 124(a1)", "e8dd"},
+		{"flw fa0, 4(a1)", "c861"},
+		{"fsd fs0, 8(sp)", "22a4"},
+		{"jalr x0, 0(ra)", "8280"},
+		{"jalr ra, 0(a0)", "0295"},
+		{"jalr x0, 4(ra)", "67804000"},
+		{"ret", "8280"},
+		{"ebreak", "0290"},
+		{"jal x0, 16", "01a8"},
+		{"jal ra, -16", "c53f"},
+		{"bne a0, x0, 8", "01e5"},
+		{"beq s0, x0, -8", "65dc"},
+		{"beq a0, a1, 8", "6304b500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			a := &Assembler{RVC: true}
+			a.compilation.labelPositions = map[string]int{}
+			got, err := a.AssembleLine(tt.line)
+			if err != nil {
+				t.Fatalf("AssembleLine(%q) error = %v", tt.line, err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("AssembleLine(%q) = %x, want %s", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_CompressionRV64(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"ld a0, 8(a1)", "8865"},
+		{"sd ra, 8(sp)", "06e4"},
+		{"addiw a0, a0, -1", "7d35"},
+		{"addw a0, a0, a1", "2d9d"},
+		{"flw fa0, 4(a1)", "07a54500"},
+		{"jal ra, 16", "ef000001"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			a := &Assembler{Arch: RV64, RVC: true}
+			a.compilation.labelPositions = map[string]int{}
+			got, err := a.AssembleLine(tt.line)
+			if err != nil {
+				t.Fatalf("AssembleLine(%q) error = %v", tt.line, err)
+			}
+			if hex.EncodeToString(got) != tt.want {
+				t.Errorf("AssembleLine(%q) = %x, want %s", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_Parse_Option(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		want    string
+		wantErr bool
+	}{
+		{"rvc", []string{".option rvc", "addi a0, a0, 1"}, "0505", false},
+		{"norvc", []string{".option rvc", ".option norvc", "addi a0, a0, 1"}, "13051500", false},
+		{"push pop", []string{".option rvc", ".option push", ".option norvc", ".option pop", "addi a0, a0, 1"}, "0505", false},
+		{"pop without push", []string{".option pop"}, "", true},
+		{"unknown", []string{".option foo"}, "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := &Assembler{}
+			a.compilation.labelPositions = map[string]int{}
+			var got []byte
+			var err error
+			for _, line := range tt.lines {
+				got, err = a.AssembleLine(line)
+				if err != nil {
+					break
+				}
+			}
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("AssembleLine() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && hex.EncodeToString(got) != tt.want {
+				t.Errorf("AssembleLine() = %x, want %s", got, tt.want)
+			}
+		})
+	}
+}
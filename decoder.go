@@ -0,0 +1,78 @@
+package assembler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/bits"
+	"strings"
+)
+
+// intRegisterNames holds the ABI names of x0-x31, the ones Decode prints
+var intRegisterNames = [32]string{
+	"zero", "ra", "sp", "gp", "tp", "t0", "t1", "t2", "s0", "s1", "a0", "a1", "a2", "a3", "a4", "a5",
+	"a6", "a7", "s2", "s3", "s4", "s5", "s6", "s7", "s8", "s9", "s10", "s11", "t3", "t4", "t5", "t6",
+}
+
+// floatRegisterNames holds the ABI names of f0-f31
+var floatRegisterNames = [32]string{
+	"ft0", "ft1", "ft2", "ft3", "ft4", "ft5", "ft6", "ft7", "fs0", "fs1", "fa0", "fa1", "fa2", "fa3", "fa4", "fa5",
+	"fa6", "fa7", "fs2", "fs3", "fs4", "fs5", "fs6", "fs7", "fs8", "fs9", "fs10", "fs11", "ft8", "ft9", "ft10", "ft11",
+}
+
+// Decode disassembles the instruction at the start of code for arch and returns its text
+// along with its size in bytes, 2 for the compressed instructions and 4 otherwise. Only the
+// standard instructions of instructions.spec are known to it
+func Decode(code []byte, arch Arch) (string, int, error) {
+	if len(code) < 2 {
+		return "", 0, errors.New("decode: an instruction takes at least 2 bytes")
+	}
+	size := 2
+	word := uint32(binary.LittleEndian.Uint16(code))
+	if word&0b11 == 0b11 {
+		if len(code) < 4 {
+			return "", 0, errors.New("decode: truncated 32 bit instruction")
+		}
+		size = 4
+		word = binary.LittleEndian.Uint32(code)
+	}
+	row := standardSpec.decode(word, size, arch.xlen())
+	if row == nil {
+		return "", size, fmt.Errorf("decode: unknown instruction %#0*x", size*2+2, word)
+	}
+	var operands []string
+	for _, name := range row.forms[0] {
+		if text := specOperands[strings.TrimSuffix(name, "?")].text(row, word, arch.xlen()); text != "" {
+			operands = append(operands, text)
+		}
+	}
+	if len(operands) == 0 {
+		return row.name, size, nil
+	}
+	return row.name + " " + strings.Join(operands, ", "), size, nil
+}
+
+// decode returns the row of base xlen matching word with the most fixed bits, so c.nop
+// wins over c.addi and c.jr over c.mv
+func (s *isaSpec) decode(word uint32, size int, xlen int) *specRow {
+	var best *specRow
+	for _, row := range s.rows {
+		if row.pair.opType.size() != size || row.xlenOr(xlen) != xlen || word&row.mask != row.match || row.reserved(word) {
+			continue
+		}
+		if best == nil || bits.OnesCount32(row.mask) > bits.OnesCount32(best.mask) {
+			best = row
+		}
+	}
+	return best
+}
+
+// reserved reports whether word leaves the immediate of a compressed row at 0 where its
+// layout forbids it, such as the all zero c.addi4spn
+func (r *specRow) reserved(word uint32) bool {
+	switch r.pair.opType {
+	case CI, CSS, CIW, CL, CS, CB, CJ:
+		return compressedLayouts[r.pair.opByte[2]].nonzero && r.layoutValue(word) == 0
+	}
+	return false
+}
@@ -1,5 +1,7 @@
 package assembler
 
+import "strconv"
+
 type OpCode int
 
 const (
@@ -9,14 +11,29 @@ const (
 	B
 	U
 	J
-	CI  // Compressed I-type
-	CSS // Compressed S-type
-	CL  // Compressed Load
-	CJ  // Compressed Jump
-	CR  // Compressed R-type
-	CB  // Compressed Branch
-	CIW // Compressed Immediate Word
-	CS  // Compressed Store
+	CI    // Compressed I-type
+	CSS   // Compressed S-type
+	CL    // Compressed Load
+	CJ    // Compressed Jump
+	CR    // Compressed R-type
+	CB    // Compressed Branch
+	CIW   // Compressed Immediate Word
+	CS    // Compressed Store
+	A     // Atomic R-type (rd, rs2, (rs1))
+	R4    // Fused multiply-add (rd, rs1, rs2, rs3)
+	CSR   // Control and status register access (rd, csr, rs1/uimm)
+	SYS   // System instruction with fixed funct12 or optional (rs1, rs2)
+	FENCE // Memory ordering (pred, succ)
+	CA    // Compressed arithmetic (rd', rs2')
+	BS    // R-type with a byte select immediate in func7 (rd, rs1, rs2, bs)
+	VSET  // Vector configuration (rd, rs1/uimm, vtype)
+	VMEM  // Vector unit-stride load and store (vd, (rs1), v0.t)
+	V     // Vector integer arithmetic OP-V (vd, vs2, vs1/rs1/imm, v0.t)
+	CMO   // Cache block management and prefetch hints (offset(rs1))
+	CMPP  // Compressed push/pop ({rlist}, stack_adj)
+	CMMV  // Compressed move between s0-s7 and a0-a1 (r1s', r2s')
+	INSN  // Raw 32 bit word written with .insn
+	CINSN // Raw 16 bit parcel written with .insn
 )
 
 // String method to return the name of OpCode instead of its numeric value
@@ -24,6 +41,8 @@ func (op OpCode) String() string {
 	names := []string{
 		"R", "I", "S", "B", "U", "J",
 		"CI", "CSS", "CL", "CJ", "CR", "CB", "CIW", "CS",
+		"A", "R4", "CSR", "SYS", "FENCE", "CA", "BS", "VSET", "VMEM", "V", "CMO",
+		"CMPP", "CMMV", "INSN", "CINSN",
 	}
 	if op >= 0 && int(op) < len(names) {
 		return names[op]
@@ -31,91 +50,381 @@ func (op OpCode) String() string {
 	return "Unknown"
 }
 
+// size returns the number of bytes an instruction of this format occupies
+func (op OpCode) size() int {
+	switch op {
+	case CI, CSS, CL, CJ, CR, CB, CIW, CS, CA, CMPP, CMMV, CINSN:
+		return 2
+	}
+	return 4
+}
+
+// Arch selects the base integer ISA the assembler targets
+type Arch int
+
+const (
+	RV32 Arch = iota
+	RV64
+	RV32E // RV32 with only the registers x0-x15
+)
+
+func (a Arch) String() string {
+	switch a {
+	case RV32:
+		return "RV32"
+	case RV64:
+		return "RV64"
+	case RV32E:
+		return "RV32E"
+	}
+	return "Unknown"
+}
+
+// xlen returns the width of the integer registers in bits
+func (a Arch) xlen() int {
+	if a == RV64 {
+		return 64
+	}
+	return 32
+}
+
 type OpPair struct {
 	opType OpCode
 	opByte []byte
 }
 
-// PseudoToInstruction here we handle both pseudo instructions and compressed ones
-// since current cpu is not capable of running compressed instruction we decompress them
-var PseudoToInstruction = map[string]func([]string) []string{
-	"mv":  handleMV,
-	"j":   handleJ,
-	"jal": handleJAL,
-	"jr":  handleJR,
-	"add": handleADD,
-	"sub": handleSUB,
-	"ble": handleBLE,
-	"li":  handleLI,
-	"la":  handleLA,
-	"ret": handleRET,
-	"nop": handleNOP,
-}
-
-var InstructionToOpType = map[string]OpPair{
-
-	// U TYPE
-	"lui":   {U, []byte{0b0110111}},
-	"auipc": {U, []byte{0b0010111}},
-
-	// J TYPE
-	"jal": {J, []byte{0b1101111}},
-
-	// B TYPE
-	"beq":  {B, []byte{0b1100011, 0x0}},
-	"bne":  {B, []byte{0b1100011, 0x1}},
-	"blt":  {B, []byte{0b1100011, 0x4}},
-	"bge":  {B, []byte{0b1100011, 0x5}},
-	"bltu": {B, []byte{0b1100011, 0x6}},
-	"bgeu": {B, []byte{0b1100011, 0x7}},
-
-	// I TYPE: opbyte = opcode, func3, imm[11:5]
-	"jalr":   {I, []byte{0b1100111, 0x0, 0x0}},
-	"lb":     {I, []byte{0b0000011, 0x0, 0x0}},
-	"lh":     {I, []byte{0b0000011, 0x1, 0x0}},
-	"lw":     {I, []byte{0b0000011, 0x2, 0x0}},
-	"lbu":    {I, []byte{0b0000011, 0x4, 0x0}},
-	"lhu":    {I, []byte{0b0000011, 0x5, 0x0}},
-	"sb":     {S, []byte{0b0100011, 0x0, 0x0}},
-	"sh":     {S, []byte{0b0100011, 0x1, 0x0}},
-	"sw":     {S, []byte{0b0100011, 0x2, 0x0}},
-	"addi":   {I, []byte{0b0010011, 0x0, 0x0}},
-	"slti":   {I, []byte{0b0010011, 0x2, 0x0}},
-	"sltiu":  {I, []byte{0b0010011, 0x3, 0x0}},
-	"xori":   {I, []byte{0b0010011, 0x4, 0x0}},
-	"ori":    {I, []byte{0b0010011, 0x6, 0x0}},
-	"andi":   {I, []byte{0b0010011, 0x7, 0x0}},
-	"slli":   {I, []byte{0b0010011, 0x1, 0x00}},
-	"srli":   {I, []byte{0b0010011, 0x5, 0x00}},
-	"srai":   {I, []byte{0b0010011, 0x5, 0x20}},
-	"ebreak": {I, []byte{0b1110011, 0x0, 0x1}}, // Adjust as needed
-	"ecall":  {I, []byte{0b1110011, 0x0, 0x0}}, // Adjust as needed
-
-	// R TYPE
-	"add":  {R, []byte{0b0110011, 0x0, 0x00}},
-	"sub":  {R, []byte{0b0110011, 0x0, 0x20}},
-	"sll":  {R, []byte{0b0110011, 0x1, 0x00}},
-	"slt":  {R, []byte{0b0110011, 0x2, 0x00}},
-	"sltu": {R, []byte{0b0110011, 0x3, 0x00}},
-	"xor":  {R, []byte{0b0110011, 0x4, 0x00}},
-	"srl":  {R, []byte{0b0110011, 0x5, 0x00}},
-	"sra":  {R, []byte{0b0110011, 0x5, 0x20}},
-	"or":   {R, []byte{0b0110011, 0x6, 0x00}},
-	"and":  {R, []byte{0b0110011, 0x7, 0x00}},
-	"nop":  {R, []byte{0b0000000, 0x0, 0x0}}, // Representing NOP, adjust as necessary
-
-	// C TYPE (Compressed Instructions)
-	"c.add": {CR, []byte{0b000000, 0b000, 0b000, 0b000, 0b000, 0b0110011}}, // C-type add
-	"c.sub": {CR, []byte{0b000000, 0b000, 0b000, 0b000, 0b001, 0b0110011}}, // C-type sub
-	"c.nop": {CR, []byte{0b000000, 0b000, 0b000, 0b000, 0b001, 0b0000000}}, // C-type nop
-	"c.j":   {CJ, []byte{0b1100011}},                                       // Compressed jump
-	"c.jal": {CJ, []byte{0b1101111}},                                       // Compressed jump and link
-	"c.beq": {CB, []byte{0b000000}},                                        // Compressed branch if equal
-	"c.bne": {CB, []byte{0b000001}},                                        // Compressed branch if not equal
-	"c.lw":  {CL, []byte{0b000001}},                                        // Compressed load word
-	"c.sw":  {CS, []byte{0b000001}},                                        // Compressed store word
+// pseudoToInstructionRV64 overrides pseudoToInstruction when targeting RV64
+var pseudoToInstructionRV64 = map[string]pseudoHandler{
+	"li":     pseudo("li", expandForm("rd,imm", handleLI64)),
+	"ld":     pseudo("ld", form("rd,mem"), expandForm("rd,symbol", symbolAccess("ld"))),
+	"lwu":    pseudo("lwu", form("rd,mem"), expandForm("rd,symbol", symbolAccess("lwu"))),
+	"sd":     pseudo("sd", form("rs,mem"), expandForm("rs,symbol,rt", symbolAccess("sd"))),
+	"sext.w": pseudo("sext.w", form("rd,rs", "addiw %[1]s, %[2]s, 0")),
+	"negw":   pseudo("negw", form("rd,rs", "subw %[1]s, x0, %[2]s")),
+	"sext.b": pseudo("sext.b", form("rd,rs", "slli %[1]s, %[2]s, 56", "srai %[1]s, %[1]s, 56")),
+	"sext.h": pseudo("sext.h", form("rd,rs", "slli %[1]s, %[2]s, 48", "srai %[1]s, %[1]s, 48")),
+	"zext.h": pseudo("zext.h", form("rd,rs", "slli %[1]s, %[2]s, 48", "srli %[1]s, %[1]s, 48")),
+	"zext.w": pseudo("zext.w", form("rd,rs", "slli %[1]s, %[2]s, 32", "srli %[1]s, %[1]s, 32")),
+}
+
+// pseudoToInstruction expands the standard pseudo instructions of the RISC-V assembly
+// manual into base instructions
+var pseudoToInstruction = map[string]pseudoHandler{
+	"nop": pseudo("nop", form("", "addi x0, x0, 0")),
+	"li":  pseudo("li", expandForm("rd,imm", handleLI)),
+	"la":  pseudo("la", form("rd,symbol", "auipc %[1]s, %%pcrel_hi(%[2]s)", "addi %[1]s, %[1]s, %%pcrel_lo(%[2]s)")),
+	"lla": pseudo("lla", form("rd,symbol", "auipc %[1]s, %%pcrel_hi(%[2]s)", "addi %[1]s, %[1]s, %%pcrel_lo(%[2]s)")),
+	"mv":  pseudo("mv", form("rd,rs", "addi %[1]s, %[2]s, 0")),
+	"not": pseudo("not", form("rd,rs", "xori %[1]s, %[2]s, -1")),
+	"neg": pseudo("neg", form("rd,rs", "sub %[1]s, x0, %[2]s")),
+	"add": pseudo("add", form("rd,rs1,rs2"), form("rd,rs", "add %[1]s, %[1]s, %[2]s")),
+	"sub": pseudo("sub", form("rd,rs1,rs2"), form("rd,rs", "sub %[1]s, %[1]s, %[2]s")),
+
+	"zext.b": pseudo("zext.b", form("rd,rs", "andi %[1]s, %[2]s, 255")),
+	"sext.b": pseudo("sext.b", form("rd,rs", "slli %[1]s, %[2]s, 24", "srai %[1]s, %[1]s, 24")),
+	"sext.h": pseudo("sext.h", form("rd,rs", "slli %[1]s, %[2]s, 16", "srai %[1]s, %[1]s, 16")),
+	"zext.h": pseudo("zext.h", form("rd,rs", "slli %[1]s, %[2]s, 16", "srli %[1]s, %[1]s, 16")),
+
+	"seqz": pseudo("seqz", form("rd,rs", "sltiu %[1]s, %[2]s, 1")),
+	"snez": pseudo("snez", form("rd,rs", "sltu %[1]s, x0, %[2]s")),
+	"sltz": pseudo("sltz", form("rd,rs", "slt %[1]s, %[2]s, x0")),
+	"sgtz": pseudo("sgtz", form("rd,rs", "slt %[1]s, x0, %[2]s")),
+
+	"beqz": pseudo("beqz", form("rs,offset", "beq %[1]s, x0, %[2]s")),
+	"bnez": pseudo("bnez", form("rs,offset", "bne %[1]s, x0, %[2]s")),
+	"blez": pseudo("blez", form("rs,offset", "bge x0, %[1]s, %[2]s")),
+	"bgez": pseudo("bgez", form("rs,offset", "bge %[1]s, x0, %[2]s")),
+	"bltz": pseudo("bltz", form("rs,offset", "blt %[1]s, x0, %[2]s")),
+	"bgtz": pseudo("bgtz", form("rs,offset", "blt x0, %[1]s, %[2]s")),
+	"bgt":  pseudo("bgt", form("rs,rt,offset", "blt %[2]s, %[1]s, %[3]s")),
+	"ble":  pseudo("ble", form("rs,rt,offset", "bge %[2]s, %[1]s, %[3]s")),
+	"bgtu": pseudo("bgtu", form("rs,rt,offset", "bltu %[2]s, %[1]s, %[3]s")),
+	"bleu": pseudo("bleu", form("rs,rt,offset", "bgeu %[2]s, %[1]s, %[3]s")),
+
+	"j":    pseudo("j", form("offset", "jal x0, %[1]s")),
+	"jal":  pseudo("jal", form("offset", "jal x1, %[1]s"), form("rd,offset")),
+	"jr":   pseudo("jr", form("rs", "jalr x0, %[1]s, 0")),
+	"jalr": pseudo("jalr", form("rs", "jalr x1, %[1]s, 0"), form("rd,rs,offset"), form("rd,mem")),
+	"ret":  pseudo("ret", form("", "jalr x0, 0(x1)")),
+	"call": pseudo("call", form("symbol", "auipc x1, %%pcrel_hi(%[1]s)", "jalr x1, x1, %%pcrel_lo(%[1]s)"),
+		form("rd,symbol", "auipc %[1]s, %%pcrel_hi(%[2]s)", "jalr %[1]s, %[1]s, %%pcrel_lo(%[2]s)")),
+	"tail": pseudo("tail", form("symbol", "auipc x6, %%pcrel_hi(%[1]s)", "jalr x0, x6, %%pcrel_lo(%[1]s)")),
+
+	"lb":  pseudo("lb", form("rd,mem"), expandForm("rd,symbol", symbolAccess("lb"))),
+	"lh":  pseudo("lh", form("rd,mem"), expandForm("rd,symbol", symbolAccess("lh"))),
+	"lw":  pseudo("lw", form("rd,mem"), expandForm("rd,symbol", symbolAccess("lw"))),
+	"lbu": pseudo("lbu", form("rd,mem"), expandForm("rd,symbol", symbolAccess("lbu"))),
+	"lhu": pseudo("lhu", form("rd,mem"), expandForm("rd,symbol", symbolAccess("lhu"))),
+	"sb":  pseudo("sb", form("rs,mem"), expandForm("rs,symbol,rt", symbolAccess("sb"))),
+	"sh":  pseudo("sh", form("rs,mem"), expandForm("rs,symbol,rt", symbolAccess("sh"))),
+	"sw":  pseudo("sw", form("rs,mem"), expandForm("rs,symbol,rt", symbolAccess("sw"))),
+	"flw": pseudo("flw", form("fd,mem"), expandForm("fd,symbol,rt", symbolAccess("flw"))),
+	"fld": pseudo("fld", form("fd,mem"), expandForm("fd,symbol,rt", symbolAccess("fld"))),
+	"fsw": pseudo("fsw", form("fs,mem"), expandForm("fs,symbol,rt", symbolAccess("fsw"))),
+	"fsd": pseudo("fsd", form("fs,mem"), expandForm("fs,symbol,rt", symbolAccess("fsd"))),
+
+	"fence": pseudo("fence", form("", "fence iorw, iorw"), form("pred,succ")),
+
+	"fmv.s":  pseudo("fmv.s", form("fd,fs", "fsgnj.s %[1]s, %[2]s, %[2]s")),
+	"fabs.s": pseudo("fabs.s", form("fd,fs", "fsgnjx.s %[1]s, %[2]s, %[2]s")),
+	"fneg.s": pseudo("fneg.s", form("fd,fs", "fsgnjn.s %[1]s, %[2]s, %[2]s")),
+	"fmv.d":  pseudo("fmv.d", form("fd,fs", "fsgnj.d %[1]s, %[2]s, %[2]s")),
+	"fabs.d": pseudo("fabs.d", form("fd,fs", "fsgnjx.d %[1]s, %[2]s, %[2]s")),
+	"fneg.d": pseudo("fneg.d", form("fd,fs", "fsgnjn.d %[1]s, %[2]s, %[2]s")),
+
+	"csrr":       pseudo("csrr", form("rd,csr", "csrrs %[1]s, %[2]s, x0")),
+	"csrw":       pseudo("csrw", form("csr,rs", "csrrw x0, %[1]s, %[2]s")),
+	"csrs":       pseudo("csrs", form("csr,rs", "csrrs x0, %[1]s, %[2]s")),
+	"csrc":       pseudo("csrc", form("csr,rs", "csrrc x0, %[1]s, %[2]s")),
+	"csrwi":      pseudo("csrwi", form("csr,imm", "csrrwi x0, %[1]s, %[2]s")),
+	"csrsi":      pseudo("csrsi", form("csr,imm", "csrrsi x0, %[1]s, %[2]s")),
+	"csrci":      pseudo("csrci", form("csr,imm", "csrrci x0, %[1]s, %[2]s")),
+	"rdcycle":    pseudo("rdcycle", form("rd", "csrrs %[1]s, cycle, x0")),
+	"rdtime":     pseudo("rdtime", form("rd", "csrrs %[1]s, time, x0")),
+	"rdinstret":  pseudo("rdinstret", form("rd", "csrrs %[1]s, instret, x0")),
+	"rdcycleh":   pseudo("rdcycleh", form("rd", "csrrs %[1]s, cycleh, x0")),
+	"rdtimeh":    pseudo("rdtimeh", form("rd", "csrrs %[1]s, timeh, x0")),
+	"rdinstreth": pseudo("rdinstreth", form("rd", "csrrs %[1]s, instreth, x0")),
+
+	"frcsr":    pseudo("frcsr", form("rd", "csrrs %[1]s, fcsr, x0")),
+	"fscsr":    pseudo("fscsr", form("rs", "csrrw x0, fcsr, %[1]s"), form("rd,rs", "csrrw %[1]s, fcsr, %[2]s")),
+	"frrm":     pseudo("frrm", form("rd", "csrrs %[1]s, frm, x0")),
+	"fsrm":     pseudo("fsrm", form("rs", "csrrw x0, frm, %[1]s"), form("rd,rs", "csrrw %[1]s, frm, %[2]s")),
+	"fsrmi":    pseudo("fsrmi", form("imm", "csrrwi x0, frm, %[1]s"), form("rd,imm", "csrrwi %[1]s, frm, %[2]s")),
+	"frflags":  pseudo("frflags", form("rd", "csrrs %[1]s, fflags, x0")),
+	"fsflags":  pseudo("fsflags", form("rs", "csrrw x0, fflags, %[1]s"), form("rd,rs", "csrrw %[1]s, fflags, %[2]s")),
+	"fsflagsi": pseudo("fsflagsi", form("imm", "csrrwi x0, fflags, %[1]s"), form("rd,imm", "csrrwi %[1]s, fflags, %[2]s")),
+}
+
+// instructionPseudos lists the pseudo instructions sharing their name with an extension
+// instruction, they expand unless an ISA string enables that instruction
+var instructionPseudos = map[string]bool{"sext.b": true, "sext.h": true, "zext.h": true, "zext.w": true}
+
+// instructionToOpType holds the standard instructions of every base, built from the rows
+// of instructions.spec, NewInstructionSet copies it so the Assemblers never share or modify it
+var instructionToOpType = standardSpec.opTypes(0)
+
+// InsnOpcodes names the major opcodes accepted by the .insn directive
+var InsnOpcodes = map[string]int{
+	"LOAD":      0x03,
+	"LOAD_FP":   0x07,
+	"CUSTOM_0":  0x0B,
+	"MISC_MEM":  0x0F,
+	"OP_IMM":    0x13,
+	"AUIPC":     0x17,
+	"OP_IMM_32": 0x1B,
+	"STORE":     0x23,
+	"STORE_FP":  0x27,
+	"CUSTOM_1":  0x2B,
+	"AMO":       0x2F,
+	"OP":        0x33,
+	"LUI":       0x37,
+	"OP_32":     0x3B,
+	"MADD":      0x43,
+	"MSUB":      0x47,
+	"NMSUB":     0x4B,
+	"NMADD":     0x4F,
+	"OP_FP":     0x53,
+	"OP_V":      0x57,
+	"CUSTOM_2":  0x5B,
+	"BRANCH":    0x63,
+	"JALR":      0x67,
+	"JAL":       0x6F,
+	"SYSTEM":    0x73,
+	"CUSTOM_3":  0x7B,
+}
+
+// insnFormats gives the type each .insn format is encoded as and the largest value
+// of the fields following the opcode: func3, func7 for r, func3, func2 for r4...
+var insnFormats = map[string]struct {
+	opType OpCode
+	fields []int
+}{
+	"r":  {R, []int{7, 127}},
+	"r4": {R4, []int{7, 3}},
+	"i":  {I, []int{7}},
+	"s":  {S, []int{7}},
+	"b":  {B, []int{7}},
+	"u":  {U, nil},
+	"j":  {J, nil},
+}
+
+// immediate layouts of the compressed instructions, indexes into compressedLayouts
+const (
+	cImm6 = iota
+	cShamt
+	cLui
+	cAddi16sp
+	cLwsp
+	cLdsp
+	cSwsp
+	cSdsp
+	cAddi4spn
+	cLw
+	cLd
+	cBranch
+	cJump
+	cLbu
+	cLh
+)
+
+// compressedLayout describes where the bits of an immediate are scattered in a 16 bit instruction
+type compressedLayout struct {
+	signed  bool
+	nonzero bool    // the zero immediate encodes a reserved instruction
+	bits    [11]int // immediate bit held by instruction bits 12 down to 2, -1 when not part of the immediate
+}
+
+var compressedLayouts = []compressedLayout{
+	cImm6:     {true, false, [11]int{5, -1, -1, -1, -1, -1, 4, 3, 2, 1, 0}},
+	cShamt:    {false, false, [11]int{5, -1, -1, -1, -1, -1, 4, 3, 2, 1, 0}},
+	cLui:      {true, true, [11]int{17, -1, -1, -1, -1, -1, 16, 15, 14, 13, 12}},
+	cAddi16sp: {true, true, [11]int{9, -1, -1, -1, -1, -1, 4, 6, 8, 7, 5}},
+	cLwsp:     {false, false, [11]int{5, -1, -1, -1, -1, -1, 4, 3, 2, 7, 6}},
+	cLdsp:     {false, false, [11]int{5, -1, -1, -1, -1, -1, 4, 3, 8, 7, 6}},
+	cSwsp:     {false, false, [11]int{5, 4, 3, 2, 7, 6, -1, -1, -1, -1, -1}},
+	cSdsp:     {false, false, [11]int{5, 4, 3, 8, 7, 6, -1, -1, -1, -1, -1}},
+	cAddi4spn: {false, true, [11]int{5, 4, 9, 8, 7, 6, 2, 3, -1, -1, -1}},
+	cLw:       {false, false, [11]int{5, 4, 3, -1, -1, -1, 2, 6, -1, -1, -1}},
+	cLd:       {false, false, [11]int{5, 4, 3, -1, -1, -1, 7, 6, -1, -1, -1}},
+	cBranch:   {true, false, [11]int{8, 4, 3, -1, -1, -1, 7, 6, 2, 1, 5}},
+	cJump:     {true, false, [11]int{11, 4, 9, 8, 10, 6, 7, 3, 2, 1, 5}},
+	cLbu:      {false, false, [11]int{-1, -1, -1, -1, -1, -1, 0, 1, -1, -1, -1}},
+	cLh:       {false, false, [11]int{-1, -1, -1, -1, -1, -1, -1, 1, -1, -1, -1}},
+}
+
+// CSRNameToAddress maps the standard CSR names to their 12 bit address
+var CSRNameToAddress = map[string]int{
+	// unprivileged floating-point
+	"fflags": 0x001,
+	"frm":    0x002,
+	"fcsr":   0x003,
+
+	// unprivileged counters and timers (read-only)
+	"cycle":    0xC00,
+	"time":     0xC01,
+	"instret":  0xC02,
+	"cycleh":   0xC80,
+	"timeh":    0xC81,
+	"instreth": 0xC82,
+
+	// supervisor
+	"sstatus":    0x100,
+	"sie":        0x104,
+	"stvec":      0x105,
+	"scounteren": 0x106,
+	"senvcfg":    0x10A,
+	"sscratch":   0x140,
+	"sepc":       0x141,
+	"scause":     0x142,
+	"stval":      0x143,
+	"sip":        0x144,
+	"satp":       0x180,
+
+	// machine information (read-only)
+	"mvendorid":  0xF11,
+	"marchid":    0xF12,
+	"mimpid":     0xF13,
+	"mhartid":    0xF14,
+	"mconfigptr": 0xF15,
+
+	// machine trap setup and handling
+	"mstatus":    0x300,
+	"misa":       0x301,
+	"medeleg":    0x302,
+	"mideleg":    0x303,
+	"mie":        0x304,
+	"mtvec":      0x305,
+	"mcounteren": 0x306,
+	"mstatush":   0x310,
+	"mscratch":   0x340,
+	"mepc":       0x341,
+	"mcause":     0x342,
+	"mtval":      0x343,
+	"mip":        0x344,
+	"mtinst":     0x34A,
+	"mtval2":     0x34B,
+	"menvcfg":    0x30A,
+	"menvcfgh":   0x31A,
+
+	// machine counters
+	"mcycle":        0xB00,
+	"minstret":      0xB02,
+	"mcycleh":       0xB80,
+	"minstreth":     0xB82,
+	"mcountinhibit": 0x320,
+
+	// debug and trigger
+	"tselect":   0x7A0,
+	"tdata1":    0x7A1,
+	"tdata2":    0x7A2,
+	"tdata3":    0x7A3,
+	"dcsr":      0x7B0,
+	"dpc":       0x7B1,
+	"dscratch0": 0x7B2,
+	"dscratch1": 0x7B3,
+}
+
+// register the numbered CSR families (pmp, hpm counters and events)
+func init() {
+	for i := 0; i < 16; i++ {
+		CSRNameToAddress["pmpaddr"+strconv.Itoa(i)] = 0x3B0 + i
+		if i < 4 {
+			CSRNameToAddress["pmpcfg"+strconv.Itoa(i)] = 0x3A0 + i
+		}
+	}
+	for i := 3; i < 32; i++ {
+		n := strconv.Itoa(i)
+		CSRNameToAddress["hpmcounter"+n] = 0xC00 + i
+		CSRNameToAddress["hpmcounter"+n+"h"] = 0xC80 + i
+		CSRNameToAddress["mhpmcounter"+n] = 0xB00 + i
+		CSRNameToAddress["mhpmcounter"+n+"h"] = 0xB80 + i
+		CSRNameToAddress["mhpmevent"+n] = 0x320 + i
+	}
+}
+
+// isReadOnlyCSR reports whether the CSR address encodes a read-only register (bits 11:10 set)
+func isReadOnlyCSR(address int) bool {
+	return (address>>10)&0b11 == 0b11
+}
+
+// roundingModes maps the floating-point rounding mode operands to their func3 value
+var roundingModes = map[string]int{
+	"rne": 0b000,
+	"rtz": 0b001,
+	"rdn": 0b010,
+	"rup": 0b011,
+	"rmm": 0b100,
+	"dyn": 0b111,
+}
+
+// vectorSEW maps the element width of a vtype operand to its vsew field
+var vectorSEW = map[string]int{"e8": 0, "e16": 1, "e32": 2, "e64": 3}
+
+// vectorLMUL maps the register grouping of a vtype operand to its vlmul field
+var vectorLMUL = map[string]int{"mf8": 5, "mf4": 6, "mf2": 7, "m1": 0, "m2": 1, "m4": 2, "m8": 3}
+
+// atomicOrderings maps the memory-ordering mnemonic suffixes to their aq/rl bits in func7
+var atomicOrderings = map[string]byte{
+	".aq":   0b10,
+	".rl":   0b01,
+	".aqrl": 0b11,
 }
 
+// instructionToOpTypeRV32 holds the instructions only available when targeting RV32,
+// their encodings are reused by RV64 for other instructions
+var instructionToOpTypeRV32 = standardSpec.opTypes(32)
+
+// instructionToOpTypeRV64 holds the instructions only available when targeting RV64
+var instructionToOpTypeRV64 = standardSpec.opTypes(64)
+
+// instructionOperands holds the operand forms of the standard instructions
+var instructionOperands = standardSpec.operandForms()
+
+// instructionRows maps the standard instructions to their row, InstructionToBinary
+// encodes their operands after its forms
+var instructionRows = standardSpec.byName()
+
 func getTType(ti TokenType) string {
 	switch ti {
 	case global:
@@ -150,6 +459,12 @@ func getTType(ti TokenType) string {
 		return "varLabel"
 	case varSize:
 		return "varSize"
+	case roundingMode:
+		return "roundingMode"
+	case vectorMask:
+		return "vectorMask"
+	case registerList:
+		return "registerList"
 	}
 	return ""
 }
@@ -184,6 +499,36 @@ func getOpCode(opc OpCode) string {
 		return ""
 	case CS:
 		return ""
+	case CA:
+		return ""
+	case CMPP:
+		return ""
+	case CMMV:
+		return ""
+	case INSN:
+		return "INSN"
+	case CINSN:
+		return ""
+	case BS:
+		return "BS"
+	case VSET:
+		return "VSET"
+	case VMEM:
+		return "VMEM"
+	case V:
+		return "V"
+	case CMO:
+		return "CMO"
+	case A:
+		return "A"
+	case R4:
+		return "R4"
+	case CSR:
+		return "CSR"
+	case SYS:
+		return "SYS"
+	case FENCE:
+		return "FENCE"
 	}
 	return ""
 }
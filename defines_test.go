@@ -22,6 +22,21 @@ func TestOpCode_String(t *testing.T) {
 		{"CB type", CB, "CB"},
 		{"CIW type", CIW, "CIW"},
 		{"CS type", CS, "CS"},
+		{"A type", A, "A"},
+		{"R4 type", R4, "R4"},
+		{"CSR type", CSR, "CSR"},
+		{"SYS type", SYS, "SYS"},
+		{"FENCE type", FENCE, "FENCE"},
+		{"CA type", CA, "CA"},
+		{"BS type", BS, "BS"},
+		{"VSET type", VSET, "VSET"},
+		{"VMEM type", VMEM, "VMEM"},
+		{"V type", V, "V"},
+		{"CMO type", CMO, "CMO"},
+		{"CMPP type", CMPP, "CMPP"},
+		{"CMMV type", CMMV, "CMMV"},
+		{"INSN type", INSN, "INSN"},
+		{"CINSN type", CINSN, "CINSN"},
 		{"Invalid type", OpCode(99), "Unknown"},
 	}
 	for _, tt := range tests {
@@ -58,6 +73,9 @@ func Test_getTType(t *testing.T) {
 		{"varValue", args{varValue}, "varValue"},
 		{"varLabel", args{varLabel}, "varLabel"},
 		{"varSize", args{varSize}, "varSize"},
+		{"roundingMode", args{roundingMode}, "roundingMode"},
+		{"vectorMask", args{vectorMask}, "vectorMask"},
+		{"registerList", args{registerList}, "registerList"},
 		{"unknown", args{TokenType(99)}, ""},
 	}
 	for _, tt := range tests {
@@ -92,6 +110,21 @@ func Test_getOpCode(t *testing.T) {
 		{"CB type", args{CB}, ""},
 		{"CIW type", args{CIW}, ""},
 		{"CS type", args{CS}, ""},
+		{"A type", args{A}, "A"},
+		{"R4 type", args{R4}, "R4"},
+		{"CSR type", args{CSR}, "CSR"},
+		{"SYS type", args{SYS}, "SYS"},
+		{"FENCE type", args{FENCE}, "FENCE"},
+		{"CA type", args{CA}, ""},
+		{"BS type", args{BS}, "BS"},
+		{"VSET type", args{VSET}, "VSET"},
+		{"VMEM type", args{VMEM}, "VMEM"},
+		{"V type", args{V}, "V"},
+		{"CMO type", args{CMO}, "CMO"},
+		{"CMPP type", args{CMPP}, ""},
+		{"CMMV type", args{CMMV}, ""},
+		{"INSN type", args{INSN}, "INSN"},
+		{"CINSN type", args{CINSN}, ""},
 		{"Invalid type", args{OpCode(99)}, ""},
 	}
 	for _, tt := range tests {
@@ -133,3 +166,25 @@ func Test_getVarSize(t *testing.T) {
 		})
 	}
 }
+
+func Test_isReadOnlyCSR(t *testing.T) {
+	tests := []struct {
+		name    string
+		address int
+		want    bool
+	}{
+		{"mstatus", CSRNameToAddress["mstatus"], false},
+		{"fcsr", CSRNameToAddress["fcsr"], false},
+		{"cycle", CSRNameToAddress["cycle"], true},
+		{"mhartid", CSRNameToAddress["mhartid"], true},
+		{"hpmcounter31h", CSRNameToAddress["hpmcounter31h"], true},
+		{"pmpaddr15", CSRNameToAddress["pmpaddr15"], false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isReadOnlyCSR(tt.address); got != tt.want {
+				t.Errorf("isReadOnlyCSR(0x%03X) = %v, want %v", tt.address, got, tt.want)
+			}
+		})
+	}
+}
@@ -45,6 +45,52 @@ func GenerateSingleELFProgramHeader(htype byte, offset [4]byte, size [4]byte, me
 	return &programHeader
 }
 
+func GenerateELF64Headers(e_entry [8]byte, e_phnum [2]byte) *[0x40]byte {
+	var elfHeader [0x40]byte
+	// Magic Number
+	elfHeader[0x0] = 0x7F
+	elfHeader[0x1] = 0x45  // E
+	elfHeader[0x2] = 0x4c  // L
+	elfHeader[0x3] = 0x46  // F
+	elfHeader[0x4] = 0x02  // 64bit
+	elfHeader[0x5] = 0x01  // LE
+	elfHeader[0x6] = 0x01  // ELF Version
+	elfHeader[0x7] = 0x03  // Linux ABI
+	elfHeader[0x10] = 0x02 // Executable
+	elfHeader[0x12] = 0xF3 // RISC-V
+	elfHeader[0x14] = 0x01
+	// EntryPoint Address
+	for i := 0; i < 8; i++ {
+		elfHeader[0x18+i] = e_entry[i]
+	}
+	elfHeader[0x20] = 0x40 // Program Header Address
+	elfHeader[0x34] = 0x40 // Len = 64 Bytes
+	elfHeader[0x36] = 0x38 // Program header entry size
+	// Amount of Entries in Program Header
+	elfHeader[0x38] = e_phnum[0]
+	elfHeader[0x39] = e_phnum[1]
+	elfHeader[0x3A] = 0x40 // Section header entry size
+	return &elfHeader
+}
+
+func GenerateSingleELF64ProgramHeader(htype byte, offset [8]byte, size [8]byte, memoffset [8]byte) *[0x38]byte {
+	var programHeader [0x38]byte
+	programHeader[0x00] = 0x01  // PT_LOAD
+	programHeader[0x04] = htype // RWX
+	for i := 0; i < 8; i++ {
+		programHeader[i+0x08] = offset[i]    // File offset
+		programHeader[i+0x10] = memoffset[i] // Memory offset
+	}
+	for i := 0; i < 8; i++ {
+		programHeader[i+0x20] = size[i] // Size
+		programHeader[i+0x28] = size[i] // Size
+	}
+	return &programHeader
+}
+
+// efRISCVRVE is the e_flags bit marking code that only uses the RV32E registers x0-x15
+const efRISCVRVE = 0x0008
+
 func BuildELFFile(program Program) *[]byte {
 	var headerAmount uint16 = 1
 
@@ -58,52 +104,144 @@ func BuildELFFile(program Program) *[]byte {
 		headerAmount++
 	}
 
-	finalOffset := uint32(headerAmount*0x20) + 0x34
-	memoffsetamt := 0
-	offset := make([]byte, 4)
-	memoffset := make([]byte, 4)
-	size := make([]byte, 4)
-	binary.LittleEndian.PutUint32(offset, finalOffset)
-	binary.LittleEndian.PutUint32(size, uint32(len(program.machinecode)))
-	binary.LittleEndian.PutUint32(memoffset, uint32(memoffsetamt))
+	// RV64 targets get ELFCLASS64 headers, everything else stays 32 bit
+	elfHeaderSize, programHeaderSize := uint64(0x34), uint64(0x20)
+	programHeader := func(htype byte, offset uint64, size uint64, memoffset uint64) []byte {
+		var off, sz, mem [4]byte
+		binary.LittleEndian.PutUint32(off[:], uint32(offset))
+		binary.LittleEndian.PutUint32(sz[:], uint32(size))
+		binary.LittleEndian.PutUint32(mem[:], uint32(memoffset))
+		return GenerateSingleELFProgramHeader(htype, off, sz, mem)[:]
+	}
+	if program.arch == RV64 {
+		elfHeaderSize, programHeaderSize = 0x40, 0x38
+		programHeader = func(htype byte, offset uint64, size uint64, memoffset uint64) []byte {
+			var off, sz, mem [8]byte
+			binary.LittleEndian.PutUint64(off[:], offset)
+			binary.LittleEndian.PutUint64(sz[:], size)
+			binary.LittleEndian.PutUint64(mem[:], memoffset)
+			return GenerateSingleELF64ProgramHeader(htype, off, sz, mem)[:]
+		}
+	}
+
+	finalOffset := uint64(headerAmount)*programHeaderSize + elfHeaderSize
+	memoffsetamt := uint64(0)
 
-	file := GenerateSingleELFProgramHeader(0x05, *(*[4]byte)(offset), *(*[4]byte)(size), *(*[4]byte)(memoffset))[:]
-	finalOffset += uint32(len(program.machinecode))
-	memoffsetamt += len(program.machinecode)
+	file := programHeader(0x05, finalOffset, uint64(len(program.machinecode)), memoffsetamt)
+	finalOffset += uint64(len(program.machinecode))
+	memoffsetamt += uint64(len(program.machinecode))
 
 	if program.variables != nil {
-		binary.LittleEndian.PutUint32(offset, finalOffset)
-		binary.LittleEndian.PutUint32(size, uint32(len(program.variables)))
-		binary.LittleEndian.PutUint32(memoffset, uint32(memoffsetamt))
-		file = append(file, GenerateSingleELFProgramHeader(0x06, *(*[4]byte)(offset), *(*[4]byte)(size), *(*[4]byte)(memoffset))[:]...)
-		finalOffset += uint32(len(program.variables))
-		memoffsetamt += len(program.variables)
+		file = append(file, programHeader(0x06, finalOffset, uint64(len(program.variables)), memoffsetamt)...)
+		finalOffset += uint64(len(program.variables))
+		memoffsetamt += uint64(len(program.variables))
 	}
 
 	if program.constants != nil {
-		binary.LittleEndian.PutUint32(offset, finalOffset)
-		binary.LittleEndian.PutUint32(size, uint32(len(program.constants)))
-		binary.LittleEndian.PutUint32(memoffset, uint32(memoffsetamt))
-		file = append(file, GenerateSingleELFProgramHeader(0x04, *(*[4]byte)(offset), *(*[4]byte)(size), *(*[4]byte)(memoffset))[:]...)
-		finalOffset += uint32(len(program.constants))
-		memoffsetamt += len(program.constants)
+		file = append(file, programHeader(0x04, finalOffset, uint64(len(program.constants)), memoffsetamt)...)
+		finalOffset += uint64(len(program.constants))
+		memoffsetamt += uint64(len(program.constants))
 	}
 
 	if program.strings != nil {
-		binary.LittleEndian.PutUint32(offset, finalOffset)
-		binary.LittleEndian.PutUint32(size, uint32(len(program.strings)))
-		binary.LittleEndian.PutUint32(memoffset, uint32(memoffsetamt))
-		file = append(file, GenerateSingleELFProgramHeader(0x04, *(*[4]byte)(offset), *(*[4]byte)(size), *(*[4]byte)(memoffset))[:]...)
-
+		file = append(file, programHeader(0x04, finalOffset, uint64(len(program.strings)), memoffsetamt)...)
 	}
 
 	hamt := make([]byte, 2)
 	binary.LittleEndian.PutUint16(hamt, headerAmount)
 
-	file = append(GenerateELFHeaders(program.entrypoint, *(*[2]byte)(hamt))[:], file...)
+	if program.arch == RV64 {
+		var entry [8]byte
+		copy(entry[:], program.entrypoint[:])
+		file = append(GenerateELF64Headers(entry, *(*[2]byte)(hamt))[:], file...)
+	} else {
+		header := GenerateELFHeaders(program.entrypoint, *(*[2]byte)(hamt))
+		if program.arch == RV32E {
+			binary.LittleEndian.PutUint32(header[0x24:], efRISCVRVE)
+		}
+		file = append(header[:], file...)
+	}
 	file = append(file, program.machinecode...)
 	file = append(file, program.variables...)
 	file = append(file, program.constants...)
 	file = append(file, program.strings...)
+	if program.isa != nil {
+		file = appendAttributesSection(file, program.isa.riscvAttributes(), program.arch == RV64)
+	}
 	return &file
 }
+
+// sectionNames is the .shstrtab of the files carrying a .riscv.attributes section
+const sectionNames = "\x00.riscv.attributes\x00.shstrtab\x00"
+
+const (
+	shtStrtab          = 3
+	shtRISCVAttributes = 0x70000003
+)
+
+func GenerateSingleELFSectionHeader(name uint32, stype uint32, offset uint32, size uint32) *[0x28]byte {
+	var sectionHeader [0x28]byte
+	binary.LittleEndian.PutUint32(sectionHeader[0x00:], name)
+	binary.LittleEndian.PutUint32(sectionHeader[0x04:], stype)
+	binary.LittleEndian.PutUint32(sectionHeader[0x10:], offset)
+	binary.LittleEndian.PutUint32(sectionHeader[0x14:], size)
+	sectionHeader[0x20] = 0x01 // Alignment
+	return &sectionHeader
+}
+
+func GenerateSingleELF64SectionHeader(name uint32, stype uint32, offset uint64, size uint64) *[0x40]byte {
+	var sectionHeader [0x40]byte
+	binary.LittleEndian.PutUint32(sectionHeader[0x00:], name)
+	binary.LittleEndian.PutUint32(sectionHeader[0x04:], stype)
+	binary.LittleEndian.PutUint64(sectionHeader[0x18:], offset)
+	binary.LittleEndian.PutUint64(sectionHeader[0x20:], size)
+	sectionHeader[0x30] = 0x01 // Alignment
+	return &sectionHeader
+}
+
+// appendAttributesSection adds the .riscv.attributes section and the section header table
+// describing it to the end of file, it is not loaded so the program headers stay the same
+func appendAttributesSection(file []byte, attributes []byte, is64 bool) []byte {
+	attributesOffset := len(file)
+	file = append(file, attributes...)
+	namesOffset := len(file)
+	file = append(file, sectionNames...)
+	align := 4
+	if is64 {
+		align = 8
+	}
+	for len(file)%align != 0 {
+		file = append(file, 0)
+	}
+	sectionHeaderOffset := len(file)
+
+	sections := []struct {
+		name, stype  uint32
+		offset, size int
+	}{
+		{}, // SHN_UNDEF
+		{1, shtRISCVAttributes, attributesOffset, len(attributes)},
+		{uint32(len(".riscv.attributes") + 2), shtStrtab, namesOffset, len(sectionNames)},
+	}
+	for _, s := range sections {
+		if is64 {
+			file = append(file, GenerateSingleELF64SectionHeader(s.name, s.stype, uint64(s.offset), uint64(s.size))[:]...)
+		} else {
+			file = append(file, GenerateSingleELFSectionHeader(s.name, s.stype, uint32(s.offset), uint32(s.size))[:]...)
+		}
+	}
+
+	// e_shoff, e_shentsize, e_shnum and e_shstrndx
+	if is64 {
+		binary.LittleEndian.PutUint64(file[0x28:], uint64(sectionHeaderOffset))
+		binary.LittleEndian.PutUint16(file[0x3A:], 0x40)
+		binary.LittleEndian.PutUint16(file[0x3C:], uint16(len(sections)))
+		binary.LittleEndian.PutUint16(file[0x3E:], uint16(len(sections)-1))
+	} else {
+		binary.LittleEndian.PutUint32(file[0x20:], uint32(sectionHeaderOffset))
+		binary.LittleEndian.PutUint16(file[0x2E:], 0x28)
+		binary.LittleEndian.PutUint16(file[0x30:], uint16(len(sections)))
+		binary.LittleEndian.PutUint16(file[0x32:], uint16(len(sections)-1))
+	}
+	return file
+}
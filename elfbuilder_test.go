@@ -1,6 +1,7 @@
 package assembler
 
 import (
+	"encoding/binary"
 	"reflect"
 	"testing"
 )
@@ -68,3 +69,69 @@ func TestBuildELFFile(t *testing.T) {
 		})
 	}
 }
+
+func TestBuildELFFile_Class(t *testing.T) {
+	tests := []struct {
+		name              string
+		arch              Arch
+		class             byte
+		headerSize        int
+		programHeaderSize int
+		flags             uint32
+	}{
+		{"RV32 emits ELFCLASS32", RV32, 0x01, 0x34, 0x20, 0},
+		{"RV64 emits ELFCLASS64", RV64, 0x02, 0x40, 0x38, 0},
+		{"RV32E emits ELFCLASS32 with EF_RISCV_RVE", RV32E, 0x01, 0x34, 0x20, 0x0008},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := Program{
+				machinecode: []byte{0x13, 0x00, 0x00, 0x00},
+				variables:   []byte{0x2A, 0x00, 0x00, 0x00},
+				entrypoint:  [4]byte{0x04, 0x00, 0x00, 0x00},
+				arch:        tt.arch,
+			}
+			file := *BuildELFFile(program)
+
+			if file[0x4] != tt.class {
+				t.Errorf("BuildELFFile() class = %d, want %d", file[0x4], tt.class)
+			}
+			wantLen := tt.headerSize + 2*tt.programHeaderSize + len(program.machinecode) + len(program.variables)
+			if len(file) != wantLen {
+				t.Fatalf("BuildELFFile() length = %d, want %d", len(file), wantLen)
+			}
+
+			var entry, phoff, codeOffset uint64
+			var phnum uint16
+			var flags uint32
+			if tt.arch == RV64 {
+				entry = binary.LittleEndian.Uint64(file[0x18:])
+				phoff = binary.LittleEndian.Uint64(file[0x20:])
+				flags = binary.LittleEndian.Uint32(file[0x30:])
+				phnum = binary.LittleEndian.Uint16(file[0x38:])
+				codeOffset = binary.LittleEndian.Uint64(file[tt.headerSize+0x08:])
+			} else {
+				entry = uint64(binary.LittleEndian.Uint32(file[0x18:]))
+				phoff = uint64(binary.LittleEndian.Uint32(file[0x1C:]))
+				flags = binary.LittleEndian.Uint32(file[0x24:])
+				phnum = binary.LittleEndian.Uint16(file[0x2C:])
+				codeOffset = uint64(binary.LittleEndian.Uint32(file[tt.headerSize+0x04:]))
+			}
+			if entry != 4 {
+				t.Errorf("BuildELFFile() entry = %d, want 4", entry)
+			}
+			if phoff != uint64(tt.headerSize) {
+				t.Errorf("BuildELFFile() program header offset = %d, want %d", phoff, tt.headerSize)
+			}
+			if flags != tt.flags {
+				t.Errorf("BuildELFFile() flags = %#x, want %#x", flags, tt.flags)
+			}
+			if phnum != 2 {
+				t.Errorf("BuildELFFile() program header count = %d, want 2", phnum)
+			}
+			if codeOffset != uint64(tt.headerSize+2*tt.programHeaderSize) {
+				t.Errorf("BuildELFFile() code offset = %d, want %d", codeOffset, tt.headerSize+2*tt.programHeaderSize)
+			}
+		})
+	}
+}
@@ -11,12 +11,21 @@ type Instruction struct {
 }
 
 type Assembler struct {
-	labels      map[string]int
-	lineNumber  int
-	Token       *Token
-	output      []uint32
-	currentPC   int
-	compilation Compilation
+	Arch  Arch   // base ISA, RV32 unless set
+	March string // -march ISA string restricting the extensions, all of them when empty
+	isa   *ISA
+	// Instructions holds the accepted mnemonics, a NewInstructionSet() when nil
+	Instructions *InstructionSet
+	RVC          bool // compress eligible instructions from the start, as .option rvc
+	rvc          bool
+	rvcStack     []bool
+	equates      equates // .equ and .set symbols of the lines given to AssembleLine
+	labels       map[string]int
+	lineNumber   int
+	Token        *Token
+	output       []uint32
+	currentPC    int
+	compilation  Compilation
 }
 
 func (a *Assembler) encodeRType(inst *Instruction) uint32 {
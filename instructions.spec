@@ -0,0 +1,442 @@
+# The standard RISC-V instructions, one per line:
+#
+#   mnemonic  base  extensions  format  fields...  operands
+#
+# base        * when the instruction exists on RV32 and RV64, rv32 or rv64 when it only
+#             exists on that base (rev8 and zext.h change encoding between the two)
+# extensions  i for the base integer ISA, a|b when either extension provides it,
+#             a+b when extension b is needed on top of a
+# format      the encoder: R, R4, I, S, B, U, J, A, CSR, SYS, FENCE, BS, VSET, VMEM, V, CMO
+#             and the 16 bit CR, CI, CSS, CIW, CL, CS, CA, CB, CJ, CMPP and CMMV
+# fields      name=value for the fixed fields of the format, see specFormats in spec.go,
+#             the ones left out are 0
+# operands    comma separated operand names, see specOperands in spec.go, a trailing ?
+#             marks an optional operand, | separates the accepted forms and - means none
+#
+# Every instruction bit not filled by an operand is fixed, which gives the match and mask
+# Decode uses. The A format rows get their .aq, .rl and .aqrl orderings added.
+
+# U TYPE
+lui         *    i           U     opcode=0x37                                       rd,imm20
+auipc       *    i           U     opcode=0x17                                       rd,imm20
+
+# J TYPE
+jal         *    i           J     opcode=0x6F                                       rd,jimm
+
+# B TYPE
+beq         *    i           B     opcode=0x63 funct3=0                              rs1,rs2,bimm
+bne         *    i           B     opcode=0x63 funct3=1                              rs1,rs2,bimm
+blt         *    i           B     opcode=0x63 funct3=4                              rs1,rs2,bimm
+bge         *    i           B     opcode=0x63 funct3=5                              rs1,rs2,bimm
+bltu        *    i           B     opcode=0x63 funct3=6                              rs1,rs2,bimm
+bgeu        *    i           B     opcode=0x63 funct3=7                              rs1,rs2,bimm
+
+jalr        *    i           I     opcode=0x67 funct3=0                              rd,rs1,imm12|rd,mem
+lb          *    i           I     opcode=0x03 funct3=0                              rd,mem
+lh          *    i           I     opcode=0x03 funct3=1                              rd,mem
+lw          *    i           I     opcode=0x03 funct3=2                              rd,mem
+lbu         *    i           I     opcode=0x03 funct3=4                              rd,mem
+lhu         *    i           I     opcode=0x03 funct3=5                              rd,mem
+sb          *    i           S     opcode=0x23 funct3=0                              rs2,smem
+sh          *    i           S     opcode=0x23 funct3=1                              rs2,smem
+sw          *    i           S     opcode=0x23 funct3=2                              rs2,smem
+addi        *    i           I     opcode=0x13 funct3=0                              rd,rs1,imm12
+slti        *    i           I     opcode=0x13 funct3=2                              rd,rs1,imm12
+sltiu       *    i           I     opcode=0x13 funct3=3                              rd,rs1,imm12
+xori        *    i           I     opcode=0x13 funct3=4                              rd,rs1,imm12
+ori         *    i           I     opcode=0x13 funct3=6                              rd,rs1,imm12
+andi        *    i           I     opcode=0x13 funct3=7                              rd,rs1,imm12
+slli        *    i           I     opcode=0x13 funct3=1 funct7=0x00                  rd,rs1,shamt
+srli        *    i           I     opcode=0x13 funct3=5 funct7=0x00                  rd,rs1,shamt
+srai        *    i           I     opcode=0x13 funct3=5 funct7=0x20                  rd,rs1,shamt
+
+# R TYPE
+add         *    i           R     opcode=0x33 funct3=0 funct7=0x00                  rd,rs1,rs2
+sub         *    i           R     opcode=0x33 funct3=0 funct7=0x20                  rd,rs1,rs2
+sll         *    i           R     opcode=0x33 funct3=1 funct7=0x00                  rd,rs1,rs2
+slt         *    i           R     opcode=0x33 funct3=2 funct7=0x00                  rd,rs1,rs2
+sltu        *    i           R     opcode=0x33 funct3=3 funct7=0x00                  rd,rs1,rs2
+xor         *    i           R     opcode=0x33 funct3=4 funct7=0x00                  rd,rs1,rs2
+srl         *    i           R     opcode=0x33 funct3=5 funct7=0x00                  rd,rs1,rs2
+sra         *    i           R     opcode=0x33 funct3=5 funct7=0x20                  rd,rs1,rs2
+or          *    i           R     opcode=0x33 funct3=6 funct7=0x00                  rd,rs1,rs2
+and         *    i           R     opcode=0x33 funct3=7 funct7=0x00                  rd,rs1,rs2
+
+# M EXTENSION (R TYPE, func7 = 0x01)
+mul         *    m           R     opcode=0x33 funct3=0 funct7=0x01                  rd,rs1,rs2
+mulh        *    m           R     opcode=0x33 funct3=1 funct7=0x01                  rd,rs1,rs2
+mulhsu      *    m           R     opcode=0x33 funct3=2 funct7=0x01                  rd,rs1,rs2
+mulhu       *    m           R     opcode=0x33 funct3=3 funct7=0x01                  rd,rs1,rs2
+div         *    m           R     opcode=0x33 funct3=4 funct7=0x01                  rd,rs1,rs2
+divu        *    m           R     opcode=0x33 funct3=5 funct7=0x01                  rd,rs1,rs2
+rem         *    m           R     opcode=0x33 funct3=6 funct7=0x01                  rd,rs1,rs2
+remu        *    m           R     opcode=0x33 funct3=7 funct7=0x01                  rd,rs1,rs2
+
+# ZBA EXTENSION
+sh1add      *    zba         R     opcode=0x33 funct3=2 funct7=0x10                  rd,rs1,rs2
+sh2add      *    zba         R     opcode=0x33 funct3=4 funct7=0x10                  rd,rs1,rs2
+sh3add      *    zba         R     opcode=0x33 funct3=6 funct7=0x10                  rd,rs1,rs2
+
+# ZBB EXTENSION: unary forms (rd, rs1) are OP-IMM with a fixed funct12 split as func7 and rs2
+andn        *    zbb|zbkb    R     opcode=0x33 funct3=7 funct7=0x20                  rd,rs1,rs2
+orn         *    zbb|zbkb    R     opcode=0x33 funct3=6 funct7=0x20                  rd,rs1,rs2
+xnor        *    zbb|zbkb    R     opcode=0x33 funct3=4 funct7=0x20                  rd,rs1,rs2
+clz         *    zbb         R     opcode=0x13 funct3=1 funct7=0x30 rs2=0            rd,rs1
+ctz         *    zbb         R     opcode=0x13 funct3=1 funct7=0x30 rs2=1            rd,rs1
+cpop        *    zbb         R     opcode=0x13 funct3=1 funct7=0x30 rs2=2            rd,rs1
+sext.b      *    zbb         R     opcode=0x13 funct3=1 funct7=0x30 rs2=4            rd,rs1
+sext.h      *    zbb         R     opcode=0x13 funct3=1 funct7=0x30 rs2=5            rd,rs1
+max         *    zbb         R     opcode=0x33 funct3=6 funct7=0x05                  rd,rs1,rs2
+maxu        *    zbb         R     opcode=0x33 funct3=7 funct7=0x05                  rd,rs1,rs2
+min         *    zbb         R     opcode=0x33 funct3=4 funct7=0x05                  rd,rs1,rs2
+minu        *    zbb         R     opcode=0x33 funct3=5 funct7=0x05                  rd,rs1,rs2
+rol         *    zbb|zbkb    R     opcode=0x33 funct3=1 funct7=0x30                  rd,rs1,rs2
+ror         *    zbb|zbkb    R     opcode=0x33 funct3=5 funct7=0x30                  rd,rs1,rs2
+rori        *    zbb|zbkb    I     opcode=0x13 funct3=5 funct7=0x30                  rd,rs1,shamt
+orc.b       *    zbb         R     opcode=0x13 funct3=5 funct7=0x14 rs2=7            rd,rs1
+
+# ZBKB EXTENSION (ror, rol, rori, andn, orn, xnor and rev8 are shared with Zbb)
+pack        *    zbkb        R     opcode=0x33 funct3=4 funct7=0x04                  rd,rs1,rs2
+packh       *    zbkb        R     opcode=0x33 funct3=7 funct7=0x04                  rd,rs1,rs2
+brev8       *    zbkb        R     opcode=0x13 funct3=5 funct7=0x34 rs2=7            rd,rs1
+
+# ZBKC EXTENSION
+clmul       *    zbc|zbkc    R     opcode=0x33 funct3=1 funct7=0x05                  rd,rs1,rs2
+clmulh      *    zbc|zbkc    R     opcode=0x33 funct3=3 funct7=0x05                  rd,rs1,rs2
+
+# ZKNH EXTENSION
+sha256sig0  *    zknh        R     opcode=0x13 funct3=1 funct7=0x08 rs2=2            rd,rs1
+sha256sig1  *    zknh        R     opcode=0x13 funct3=1 funct7=0x08 rs2=3            rd,rs1
+sha256sum0  *    zknh        R     opcode=0x13 funct3=1 funct7=0x08 rs2=0            rd,rs1
+sha256sum1  *    zknh        R     opcode=0x13 funct3=1 funct7=0x08 rs2=1            rd,rs1
+
+# ZBS EXTENSION
+bclr        *    zbs         R     opcode=0x33 funct3=1 funct7=0x24                  rd,rs1,rs2
+bclri       *    zbs         I     opcode=0x13 funct3=1 funct7=0x24                  rd,rs1,shamt
+bext        *    zbs         R     opcode=0x33 funct3=5 funct7=0x24                  rd,rs1,rs2
+bexti       *    zbs         I     opcode=0x13 funct3=5 funct7=0x24                  rd,rs1,shamt
+binv        *    zbs         R     opcode=0x33 funct3=1 funct7=0x34                  rd,rs1,rs2
+binvi       *    zbs         I     opcode=0x13 funct3=1 funct7=0x34                  rd,rs1,shamt
+bset        *    zbs         R     opcode=0x33 funct3=1 funct7=0x14                  rd,rs1,rs2
+bseti       *    zbs         I     opcode=0x13 funct3=1 funct7=0x14                  rd,rs1,shamt
+
+lr.w        *    a           A     opcode=0x2F funct3=2 funct7=0x08                  rd,amem
+sc.w        *    a           A     opcode=0x2F funct3=2 funct7=0x0C                  rd,rs2,amem
+amoswap.w   *    a           A     opcode=0x2F funct3=2 funct7=0x04                  rd,rs2,amem
+amoadd.w    *    a           A     opcode=0x2F funct3=2 funct7=0x00                  rd,rs2,amem
+amoxor.w    *    a           A     opcode=0x2F funct3=2 funct7=0x10                  rd,rs2,amem
+amoand.w    *    a           A     opcode=0x2F funct3=2 funct7=0x30                  rd,rs2,amem
+amoor.w     *    a           A     opcode=0x2F funct3=2 funct7=0x20                  rd,rs2,amem
+amomin.w    *    a           A     opcode=0x2F funct3=2 funct7=0x40                  rd,rs2,amem
+amomax.w    *    a           A     opcode=0x2F funct3=2 funct7=0x50                  rd,rs2,amem
+amominu.w   *    a           A     opcode=0x2F funct3=2 funct7=0x60                  rd,rs2,amem
+amomaxu.w   *    a           A     opcode=0x2F funct3=2 funct7=0x70                  rd,rs2,amem
+
+# F EXTENSION
+# loads and stores follow the I and S conventions
+flw         *    f           I     opcode=0x07 funct3=2                              fd,mem
+fsw         *    f           S     opcode=0x27 funct3=2                              fs2,smem
+
+fmadd.s     *    f           R4    opcode=0x43 funct3=7 fmt=0                        fd,fs1,fs2,fs3,rm?
+fmsub.s     *    f           R4    opcode=0x47 funct3=7 fmt=0                        fd,fs1,fs2,fs3,rm?
+fnmsub.s    *    f           R4    opcode=0x4B funct3=7 fmt=0                        fd,fs1,fs2,fs3,rm?
+fnmadd.s    *    f           R4    opcode=0x4F funct3=7 fmt=0                        fd,fs1,fs2,fs3,rm?
+
+# R TYPE: func3 0x7 (dyn) accepts an optional rounding mode operand,
+fadd.s      *    f           R     opcode=0x53 funct3=7 funct7=0x00                  fd,fs1,fs2,rm?
+fsub.s      *    f           R     opcode=0x53 funct3=7 funct7=0x04                  fd,fs1,fs2,rm?
+fmul.s      *    f           R     opcode=0x53 funct3=7 funct7=0x08                  fd,fs1,fs2,rm?
+fdiv.s      *    f           R     opcode=0x53 funct3=7 funct7=0x0C                  fd,fs1,fs2,rm?
+fsqrt.s     *    f           R     opcode=0x53 funct3=7 funct7=0x2C rs2=0            fd,fs1,rm?
+fsgnj.s     *    f           R     opcode=0x53 funct3=0 funct7=0x10                  fd,fs1,fs2
+fsgnjn.s    *    f           R     opcode=0x53 funct3=1 funct7=0x10                  fd,fs1,fs2
+fsgnjx.s    *    f           R     opcode=0x53 funct3=2 funct7=0x10                  fd,fs1,fs2
+fmin.s      *    f           R     opcode=0x53 funct3=0 funct7=0x14                  fd,fs1,fs2
+fmax.s      *    f           R     opcode=0x53 funct3=1 funct7=0x14                  fd,fs1,fs2
+fcvt.w.s    *    f           R     opcode=0x53 funct3=7 funct7=0x60 rs2=0            rd,fs1,rm?
+fcvt.wu.s   *    f           R     opcode=0x53 funct3=7 funct7=0x60 rs2=1            rd,fs1,rm?
+fmv.x.w     *    f           R     opcode=0x53 funct3=0 funct7=0x70 rs2=0            rd,fs1
+feq.s       *    f           R     opcode=0x53 funct3=2 funct7=0x50                  rd,fs1,fs2
+flt.s       *    f           R     opcode=0x53 funct3=1 funct7=0x50                  rd,fs1,fs2
+fle.s       *    f           R     opcode=0x53 funct3=0 funct7=0x50                  rd,fs1,fs2
+fclass.s    *    f           R     opcode=0x53 funct3=1 funct7=0x70 rs2=0            rd,fs1
+fcvt.s.w    *    f           R     opcode=0x53 funct3=7 funct7=0x68 rs2=0            fd,rs1,rm?
+fcvt.s.wu   *    f           R     opcode=0x53 funct3=7 funct7=0x68 rs2=1            fd,rs1,rm?
+fmv.w.x     *    f           R     opcode=0x53 funct3=0 funct7=0x78 rs2=0            fd,rs1
+
+# D EXTENSION: same layout as F with fmt 01 (func7 bit 0 set)
+fld         *    d           I     opcode=0x07 funct3=3                              fd,mem
+fsd         *    d           S     opcode=0x27 funct3=3                              fs2,smem
+
+fmadd.d     *    d           R4    opcode=0x43 funct3=7 fmt=1                        fd,fs1,fs2,fs3,rm?
+fmsub.d     *    d           R4    opcode=0x47 funct3=7 fmt=1                        fd,fs1,fs2,fs3,rm?
+fnmsub.d    *    d           R4    opcode=0x4B funct3=7 fmt=1                        fd,fs1,fs2,fs3,rm?
+fnmadd.d    *    d           R4    opcode=0x4F funct3=7 fmt=1                        fd,fs1,fs2,fs3,rm?
+
+fadd.d      *    d           R     opcode=0x53 funct3=7 funct7=0x01                  fd,fs1,fs2,rm?
+fsub.d      *    d           R     opcode=0x53 funct3=7 funct7=0x05                  fd,fs1,fs2,rm?
+fmul.d      *    d           R     opcode=0x53 funct3=7 funct7=0x09                  fd,fs1,fs2,rm?
+fdiv.d      *    d           R     opcode=0x53 funct3=7 funct7=0x0D                  fd,fs1,fs2,rm?
+fsqrt.d     *    d           R     opcode=0x53 funct3=7 funct7=0x2D rs2=0            fd,fs1,rm?
+fsgnj.d     *    d           R     opcode=0x53 funct3=0 funct7=0x11                  fd,fs1,fs2
+fsgnjn.d    *    d           R     opcode=0x53 funct3=1 funct7=0x11                  fd,fs1,fs2
+fsgnjx.d    *    d           R     opcode=0x53 funct3=2 funct7=0x11                  fd,fs1,fs2
+fmin.d      *    d           R     opcode=0x53 funct3=0 funct7=0x15                  fd,fs1,fs2
+fmax.d      *    d           R     opcode=0x53 funct3=1 funct7=0x15                  fd,fs1,fs2
+fcvt.s.d    *    d           R     opcode=0x53 funct3=7 funct7=0x20 rs2=1            fd,fs1,rm?
+fcvt.d.s    *    d           R     opcode=0x53 funct3=0 funct7=0x21 rs2=0            fd,fs1  # exact, no rounding mode
+feq.d       *    d           R     opcode=0x53 funct3=2 funct7=0x51                  rd,fs1,fs2
+flt.d       *    d           R     opcode=0x53 funct3=1 funct7=0x51                  rd,fs1,fs2
+fle.d       *    d           R     opcode=0x53 funct3=0 funct7=0x51                  rd,fs1,fs2
+fclass.d    *    d           R     opcode=0x53 funct3=1 funct7=0x71 rs2=0            rd,fs1
+fcvt.w.d    *    d           R     opcode=0x53 funct3=7 funct7=0x61 rs2=0            rd,fs1,rm?
+fcvt.wu.d   *    d           R     opcode=0x53 funct3=7 funct7=0x61 rs2=1            rd,fs1,rm?
+fcvt.d.w    *    d           R     opcode=0x53 funct3=0 funct7=0x69 rs2=0            fd,rs1  # exact, no rounding mode
+fcvt.d.wu   *    d           R     opcode=0x53 funct3=0 funct7=0x69 rs2=1            fd,rs1  # exact, no rounding mode
+
+ecall       *    i           SYS   opcode=0x73 funct3=0 funct7=0x00 rs2=0            -
+ebreak      *    i           SYS   opcode=0x73 funct3=0 funct7=0x00 rs2=1            -
+uret        *    i           SYS   opcode=0x73 funct3=0 funct7=0x00 rs2=2            -
+sret        *    i           SYS   opcode=0x73 funct3=0 funct7=0x08 rs2=2            -
+mret        *    i           SYS   opcode=0x73 funct3=0 funct7=0x18 rs2=2            -
+dret        *    i           SYS   opcode=0x73 funct3=0 funct7=0x3D rs2=0x12         -
+wfi         *    i           SYS   opcode=0x73 funct3=0 funct7=0x08 rs2=5            -
+sfence.vma  *    i           SYS   opcode=0x73 funct3=0 funct7=0x09                  rs1?,rs2?
+
+fence       *    i           FENCE opcode=0x0F funct3=0 fm=0                         pred?,succ?
+fence.tso   *    i           FENCE opcode=0x0F funct3=0 fm=8 pred=0b0011 succ=0b0011 -
+pause       *    zihintpause FENCE opcode=0x0F funct3=0 fm=0 pred=0b0001 succ=0b0000 -
+fence.i     *    zifencei    FENCE opcode=0x0F funct3=1 fm=0 pred=0b0000 succ=0b0000 -
+
+cbo.inval   *    zicbom      CMO   opcode=0x0F funct3=2 funct=0                      amem
+cbo.clean   *    zicbom      CMO   opcode=0x0F funct3=2 funct=1                      amem
+cbo.flush   *    zicbom      CMO   opcode=0x0F funct3=2 funct=2                      amem
+cbo.zero    *    zicboz      CMO   opcode=0x0F funct3=2 funct=4                      amem
+prefetch.i  *    zicbop      CMO   opcode=0x13 funct3=6 funct=0                      pmem
+prefetch.r  *    zicbop      CMO   opcode=0x13 funct3=6 funct=1                      pmem
+prefetch.w  *    zicbop      CMO   opcode=0x13 funct3=6 funct=3                      pmem
+
+# ZICOND (R TYPE)
+czero.eqz   *    zicond      R     opcode=0x33 funct3=5 funct7=0x07                  rd,rs1,rs2
+czero.nez   *    zicond      R     opcode=0x33 funct3=7 funct7=0x07                  rd,rs1,rs2
+
+# V EXTENSION
+vsetvli     *    v           VSET  opcode=0x57 funct3=7 bits31_30=0                  rd,rs1,vtypei11
+vsetivli    *    v           VSET  opcode=0x57 funct3=7 bits31_30=3                  rd,uimm5,vtypei10
+vsetvl      *    v           R     opcode=0x57 funct3=7 funct7=0x40                  rd,rs1,rs2
+vle8.v      *    v           VMEM  opcode=0x07 width=0                               vd,amem,vm?
+vle16.v     *    v           VMEM  opcode=0x07 width=5                               vd,amem,vm?
+vle32.v     *    v           VMEM  opcode=0x07 width=6                               vd,amem,vm?
+vle64.v     *    v           VMEM  opcode=0x07 width=7                               vd,amem,vm?
+vse8.v      *    v           VMEM  opcode=0x27 width=0                               vs3,amem,vm?
+vse16.v     *    v           VMEM  opcode=0x27 width=5                               vs3,amem,vm?
+vse32.v     *    v           VMEM  opcode=0x27 width=6                               vs3,amem,vm?
+vse64.v     *    v           VMEM  opcode=0x27 width=7                               vs3,amem,vm?
+vadd.vv     *    v           V     opcode=0x57 funct3=0 funct6=0x00                  vd,vs2,vs1,vm?
+vadd.vx     *    v           V     opcode=0x57 funct3=4 funct6=0x00                  vd,vs2,rs1,vm?
+vadd.vi     *    v           V     opcode=0x57 funct3=3 funct6=0x00                  vd,vs2,simm5,vm?
+vsub.vv     *    v           V     opcode=0x57 funct3=0 funct6=0x02                  vd,vs2,vs1,vm?
+vsub.vx     *    v           V     opcode=0x57 funct3=4 funct6=0x02                  vd,vs2,rs1,vm?
+vrsub.vx    *    v           V     opcode=0x57 funct3=4 funct6=0x03                  vd,vs2,rs1,vm?
+vrsub.vi    *    v           V     opcode=0x57 funct3=3 funct6=0x03                  vd,vs2,simm5,vm?
+vminu.vv    *    v           V     opcode=0x57 funct3=0 funct6=0x04                  vd,vs2,vs1,vm?
+vminu.vx    *    v           V     opcode=0x57 funct3=4 funct6=0x04                  vd,vs2,rs1,vm?
+vmin.vv     *    v           V     opcode=0x57 funct3=0 funct6=0x05                  vd,vs2,vs1,vm?
+vmin.vx     *    v           V     opcode=0x57 funct3=4 funct6=0x05                  vd,vs2,rs1,vm?
+vmaxu.vv    *    v           V     opcode=0x57 funct3=0 funct6=0x06                  vd,vs2,vs1,vm?
+vmaxu.vx    *    v           V     opcode=0x57 funct3=4 funct6=0x06                  vd,vs2,rs1,vm?
+vmax.vv     *    v           V     opcode=0x57 funct3=0 funct6=0x07                  vd,vs2,vs1,vm?
+vmax.vx     *    v           V     opcode=0x57 funct3=4 funct6=0x07                  vd,vs2,rs1,vm?
+vand.vv     *    v           V     opcode=0x57 funct3=0 funct6=0x09                  vd,vs2,vs1,vm?
+vand.vx     *    v           V     opcode=0x57 funct3=4 funct6=0x09                  vd,vs2,rs1,vm?
+vand.vi     *    v           V     opcode=0x57 funct3=3 funct6=0x09                  vd,vs2,simm5,vm?
+vor.vv      *    v           V     opcode=0x57 funct3=0 funct6=0x0A                  vd,vs2,vs1,vm?
+vor.vx      *    v           V     opcode=0x57 funct3=4 funct6=0x0A                  vd,vs2,rs1,vm?
+vor.vi      *    v           V     opcode=0x57 funct3=3 funct6=0x0A                  vd,vs2,simm5,vm?
+vxor.vv     *    v           V     opcode=0x57 funct3=0 funct6=0x0B                  vd,vs2,vs1,vm?
+vxor.vx     *    v           V     opcode=0x57 funct3=4 funct6=0x0B                  vd,vs2,rs1,vm?
+vxor.vi     *    v           V     opcode=0x57 funct3=3 funct6=0x0B                  vd,vs2,simm5,vm?
+vsll.vv     *    v           V     opcode=0x57 funct3=0 funct6=0x25                  vd,vs2,vs1,vm?
+vsll.vx     *    v           V     opcode=0x57 funct3=4 funct6=0x25                  vd,vs2,rs1,vm?
+vsll.vi     *    v           V     opcode=0x57 funct3=3 funct6=0x25                  vd,vs2,uimm5,vm?
+vsrl.vv     *    v           V     opcode=0x57 funct3=0 funct6=0x28                  vd,vs2,vs1,vm?
+vsrl.vx     *    v           V     opcode=0x57 funct3=4 funct6=0x28                  vd,vs2,rs1,vm?
+vsrl.vi     *    v           V     opcode=0x57 funct3=3 funct6=0x28                  vd,vs2,uimm5,vm?
+vsra.vv     *    v           V     opcode=0x57 funct3=0 funct6=0x29                  vd,vs2,vs1,vm?
+vsra.vx     *    v           V     opcode=0x57 funct3=4 funct6=0x29                  vd,vs2,rs1,vm?
+vsra.vi     *    v           V     opcode=0x57 funct3=3 funct6=0x29                  vd,vs2,uimm5,vm?
+
+csrrw       *    zicsr       CSR   opcode=0x73 funct3=1                              rd,csr,rs1
+csrrs       *    zicsr       CSR   opcode=0x73 funct3=2                              rd,csr,rs1
+csrrc       *    zicsr       CSR   opcode=0x73 funct3=3                              rd,csr,rs1
+csrrwi      *    zicsr       CSR   opcode=0x73 funct3=5                              rd,csr,zimm
+csrrsi      *    zicsr       CSR   opcode=0x73 funct3=6                              rd,csr,zimm
+csrrci      *    zicsr       CSR   opcode=0x73 funct3=7                              rd,csr,zimm
+
+c.addi4spn  *    zca         CIW   op=0b00 funct3=0 layout=addi4spn                  rs2p,sp,cimm
+c.fld       *    zcd         CL    op=0b00 funct3=1 layout=ld                        frs2p,cmem
+c.lw        *    zca         CL    op=0b00 funct3=2 layout=lw                        rs2p,cmem
+c.fsd       *    zcd         CS    op=0b00 funct3=5 layout=ld                        frs2p,cmem
+c.sw        *    zca         CS    op=0b00 funct3=6 layout=lw                        rs2p,cmem
+c.nop       *    zca         CI    op=0b01 funct3=0 layout=imm6                      -
+c.addi      *    zca         CI    op=0b01 funct3=0 layout=imm6                      crd,cimm
+c.li        *    zca         CI    op=0b01 funct3=2 layout=imm6                      crd,cimm
+c.addi16sp  *    zca         CI    op=0b01 funct3=3 layout=addi16sp                  csp,cimm
+c.lui       *    zca         CI    op=0b01 funct3=3 layout=lui                       crd,cimm
+c.j         *    zca         CJ    op=0b01 funct3=5 layout=jump                      cimm
+c.slli      *    zca         CI    op=0b10 funct3=0 layout=shamt                     crd,cimm
+c.fldsp     *    zcd         CI    op=0b10 funct3=1 layout=ldsp                      cfd,cspmem
+c.lwsp      *    zca         CI    op=0b10 funct3=2 layout=lwsp                      crd,cspmem
+c.fsdsp     *    zcd         CSS   op=0b10 funct3=5 layout=sdsp                      cfs2,cspmem
+c.swsp      *    zca         CSS   op=0b10 funct3=6 layout=swsp                      crs2,cspmem
+c.srli      *    zca         CB    op=0b01 funct3=4 layout=shamt funct2=0            rs1p,cimm
+c.srai      *    zca         CB    op=0b01 funct3=4 layout=shamt funct2=1            rs1p,cimm
+c.andi      *    zca         CB    op=0b01 funct3=4 layout=imm6 funct2=2             rs1p,cimm
+c.beqz      *    zca         CB    op=0b01 funct3=6 layout=branch                    rs1p,cimm
+c.bnez      *    zca         CB    op=0b01 funct3=7 layout=branch                    rs1p,cimm
+c.sub       *    zca         CA    op=0b01 funct6=0x23 funct2=0                      rs1p,rs2p
+c.xor       *    zca         CA    op=0b01 funct6=0x23 funct2=1                      rs1p,rs2p
+c.or        *    zca         CA    op=0b01 funct6=0x23 funct2=2                      rs1p,rs2p
+c.and       *    zca         CA    op=0b01 funct6=0x23 funct2=3                      rs1p,rs2p
+c.jr        *    zca         CR    op=0b10 funct4=8                                  crd
+c.mv        *    zca         CR    op=0b10 funct4=8                                  crd,crs2
+c.ebreak    *    zca         CR    op=0b10 funct4=9                                  -
+c.jalr      *    zca         CR    op=0b10 funct4=9                                  crd
+c.add       *    zca         CR    op=0b10 funct4=9                                  crd,crs2
+
+# ZCB EXTENSION
+c.lbu       *    zcb         CL    op=0b00 funct3=4 layout=lbu bits12_6=0b0000000    rs2p,cmem
+c.lhu       *    zcb         CL    op=0b00 funct3=4 layout=lh bits12_6=0b0010000     rs2p,cmem
+c.lh        *    zcb         CL    op=0b00 funct3=4 layout=lh bits12_6=0b0010001     rs2p,cmem
+c.sb        *    zcb         CS    op=0b00 funct3=4 layout=lbu bits12_6=0b0100000    rs2p,cmem
+c.sh        *    zcb         CS    op=0b00 funct3=4 layout=lh bits12_6=0b0110000     rs2p,cmem
+c.mul       *    zcb+m       CA    op=0b01 funct6=0x27 funct2=2                      rs1p,rs2p
+c.zext.b    *    zcb         CA    op=0b01 funct6=0x27 funct2=3 rs2=0                rs1p
+c.sext.b    *    zcb+zbb     CA    op=0b01 funct6=0x27 funct2=3 rs2=1                rs1p
+c.zext.h    *    zcb+zbb     CA    op=0b01 funct6=0x27 funct2=3 rs2=2                rs1p
+c.sext.h    *    zcb+zbb     CA    op=0b01 funct6=0x27 funct2=3 rs2=3                rs1p
+c.not       *    zcb         CA    op=0b01 funct6=0x27 funct2=3 rs2=5                rs1p
+
+# ZCMP EXTENSION
+cm.push     *    zcmp        CMPP  op=0b10 funct3=5 funct5=0x18                      rlist,stackadj
+cm.pop      *    zcmp        CMPP  op=0b10 funct3=5 funct5=0x1A                      rlist,stackadj
+cm.popretz  *    zcmp        CMPP  op=0b10 funct3=5 funct5=0x1C                      rlist,stackadj
+cm.popret   *    zcmp        CMPP  op=0b10 funct3=5 funct5=0x1E                      rlist,stackadj
+cm.mvsa01   *    zcmp        CMMV  op=0b10 funct6=0x2B funct2=1                      r1s,r2s
+cm.mva01s   *    zcmp        CMMV  op=0b10 funct6=0x2B funct2=3                      r1s,r2s
+
+# RV32 only, their encodings are reused by RV64 for other instructions
+c.jal       rv32 zca         CJ    op=0b01 funct3=1 layout=jump                      cimm
+c.flw       rv32 zcf         CL    op=0b00 funct3=3 layout=lw                        frs2p,cmem
+c.fsw       rv32 zcf         CS    op=0b00 funct3=7 layout=lw                        frs2p,cmem
+c.flwsp     rv32 zcf         CI    op=0b10 funct3=3 layout=lwsp                      cfd,cspmem
+c.fswsp     rv32 zcf         CSS   op=0b10 funct3=7 layout=swsp                      cfs2,cspmem
+
+# ZBB EXTENSION
+rev8        rv32 zbb|zbkb    R     opcode=0x13 funct3=5 funct7=0x34 rs2=0x18         rd,rs1
+zext.h      rv32 zbb         R     opcode=0x33 funct3=4 funct7=0x04 rs2=0            rd,rs1
+
+# ZBKB EXTENSION
+zip         rv32 zbkb        R     opcode=0x13 funct3=1 funct7=0x04 rs2=0xF          rd,rs1
+unzip       rv32 zbkb        R     opcode=0x13 funct3=5 funct7=0x04 rs2=0xF          rd,rs1
+
+# ZKNH EXTENSION: the 64 bit sigma and sum functions computed from register pairs
+sha512sig0h rv32 zknh        R     opcode=0x33 funct3=0 funct7=0x2E                  rd,rs1,rs2
+sha512sig0l rv32 zknh        R     opcode=0x33 funct3=0 funct7=0x2A                  rd,rs1,rs2
+sha512sig1h rv32 zknh        R     opcode=0x33 funct3=0 funct7=0x2F                  rd,rs1,rs2
+sha512sig1l rv32 zknh        R     opcode=0x33 funct3=0 funct7=0x2B                  rd,rs1,rs2
+sha512sum0r rv32 zknh        R     opcode=0x33 funct3=0 funct7=0x28                  rd,rs1,rs2
+sha512sum1r rv32 zknh        R     opcode=0x33 funct3=0 funct7=0x29                  rd,rs1,rs2
+
+aes32esi    rv32 zkne        BS    opcode=0x33 funct3=0 funct5=0x11                  rd,rs1,rs2,bs
+aes32esmi   rv32 zkne        BS    opcode=0x33 funct3=0 funct5=0x13                  rd,rs1,rs2,bs
+aes32dsi    rv32 zknd        BS    opcode=0x33 funct3=0 funct5=0x15                  rd,rs1,rs2,bs
+aes32dsmi   rv32 zknd        BS    opcode=0x33 funct3=0 funct5=0x17                  rd,rs1,rs2,bs
+
+# RV64 only
+# I TYPE
+ld          rv64 i           I     opcode=0x03 funct3=3                              rd,mem
+lwu         rv64 i           I     opcode=0x03 funct3=6                              rd,mem
+addiw       rv64 i           I     opcode=0x1B funct3=0                              rd,rs1,imm12
+slliw       rv64 i           I     opcode=0x1B funct3=1 funct7=0x00                  rd,rs1,shamtw
+srliw       rv64 i           I     opcode=0x1B funct3=5 funct7=0x00                  rd,rs1,shamtw
+sraiw       rv64 i           I     opcode=0x1B funct3=5 funct7=0x20                  rd,rs1,shamtw
+
+# S TYPE
+sd          rv64 i           S     opcode=0x23 funct3=3                              rs2,smem
+
+# R TYPE
+addw        rv64 i           R     opcode=0x3B funct3=0 funct7=0x00                  rd,rs1,rs2
+subw        rv64 i           R     opcode=0x3B funct3=0 funct7=0x20                  rd,rs1,rs2
+sllw        rv64 i           R     opcode=0x3B funct3=1 funct7=0x00                  rd,rs1,rs2
+srlw        rv64 i           R     opcode=0x3B funct3=5 funct7=0x00                  rd,rs1,rs2
+sraw        rv64 i           R     opcode=0x3B funct3=5 funct7=0x20                  rd,rs1,rs2
+
+# M EXTENSION
+mulw        rv64 m           R     opcode=0x3B funct3=0 funct7=0x01                  rd,rs1,rs2
+divw        rv64 m           R     opcode=0x3B funct3=4 funct7=0x01                  rd,rs1,rs2
+divuw       rv64 m           R     opcode=0x3B funct3=5 funct7=0x01                  rd,rs1,rs2
+remw        rv64 m           R     opcode=0x3B funct3=6 funct7=0x01                  rd,rs1,rs2
+remuw       rv64 m           R     opcode=0x3B funct3=7 funct7=0x01                  rd,rs1,rs2
+
+# ZBA EXTENSION
+add.uw      rv64 zba         R     opcode=0x3B funct3=0 funct7=0x04                  rd,rs1,rs2
+sh1add.uw   rv64 zba         R     opcode=0x3B funct3=2 funct7=0x10                  rd,rs1,rs2
+sh2add.uw   rv64 zba         R     opcode=0x3B funct3=4 funct7=0x10                  rd,rs1,rs2
+sh3add.uw   rv64 zba         R     opcode=0x3B funct3=6 funct7=0x10                  rd,rs1,rs2
+slli.uw     rv64 zba         I     opcode=0x1B funct3=1 funct7=0x04                  rd,rs1,shamt
+zext.w      rv64 zba         R     opcode=0x3B funct3=0 funct7=0x04 rs2=0            rd,rs1  # add.uw rd, rs1, zero
+
+# ZBB EXTENSION
+clzw        rv64 zbb         R     opcode=0x1B funct3=1 funct7=0x30 rs2=0            rd,rs1
+ctzw        rv64 zbb         R     opcode=0x1B funct3=1 funct7=0x30 rs2=1            rd,rs1
+cpopw       rv64 zbb         R     opcode=0x1B funct3=1 funct7=0x30 rs2=2            rd,rs1
+rolw        rv64 zbb|zbkb    R     opcode=0x3B funct3=1 funct7=0x30                  rd,rs1,rs2
+rorw        rv64 zbb|zbkb    R     opcode=0x3B funct3=5 funct7=0x30                  rd,rs1,rs2
+roriw       rv64 zbb|zbkb    I     opcode=0x1B funct3=5 funct7=0x30                  rd,rs1,shamtw
+rev8        rv64 zbb|zbkb    R     opcode=0x13 funct3=5 funct7=0x35 rs2=0x18         rd,rs1
+zext.h      rv64 zbb         R     opcode=0x3B funct3=4 funct7=0x04 rs2=0            rd,rs1
+
+# ZBKB EXTENSION
+packw       rv64 zbkb        R     opcode=0x3B funct3=4 funct7=0x04                  rd,rs1,rs2
+
+# ZKNH EXTENSION
+sha512sig0  rv64 zknh        R     opcode=0x13 funct3=1 funct7=0x08 rs2=6            rd,rs1
+sha512sig1  rv64 zknh        R     opcode=0x13 funct3=1 funct7=0x08 rs2=7            rd,rs1
+sha512sum0  rv64 zknh        R     opcode=0x13 funct3=1 funct7=0x08 rs2=4            rd,rs1
+sha512sum1  rv64 zknh        R     opcode=0x13 funct3=1 funct7=0x08 rs2=5            rd,rs1
+
+# A EXTENSION
+lr.d        rv64 a           A     opcode=0x2F funct3=3 funct7=0x08                  rd,amem
+sc.d        rv64 a           A     opcode=0x2F funct3=3 funct7=0x0C                  rd,rs2,amem
+amoswap.d   rv64 a           A     opcode=0x2F funct3=3 funct7=0x04                  rd,rs2,amem
+amoadd.d    rv64 a           A     opcode=0x2F funct3=3 funct7=0x00                  rd,rs2,amem
+amoxor.d    rv64 a           A     opcode=0x2F funct3=3 funct7=0x10                  rd,rs2,amem
+amoand.d    rv64 a           A     opcode=0x2F funct3=3 funct7=0x30                  rd,rs2,amem
+amoor.d     rv64 a           A     opcode=0x2F funct3=3 funct7=0x20                  rd,rs2,amem
+amomin.d    rv64 a           A     opcode=0x2F funct3=3 funct7=0x40                  rd,rs2,amem
+amomax.d    rv64 a           A     opcode=0x2F funct3=3 funct7=0x50                  rd,rs2,amem
+amominu.d   rv64 a           A     opcode=0x2F funct3=3 funct7=0x60                  rd,rs2,amem
+amomaxu.d   rv64 a           A     opcode=0x2F funct3=3 funct7=0x70                  rd,rs2,amem
+
+# F AND D EXTENSIONS
+fcvt.l.s    rv64 f           R     opcode=0x53 funct3=7 funct7=0x60 rs2=2            rd,fs1,rm?
+fcvt.lu.s   rv64 f           R     opcode=0x53 funct3=7 funct7=0x60 rs2=3            rd,fs1,rm?
+fcvt.s.l    rv64 f           R     opcode=0x53 funct3=7 funct7=0x68 rs2=2            fd,rs1,rm?
+fcvt.s.lu   rv64 f           R     opcode=0x53 funct3=7 funct7=0x68 rs2=3            fd,rs1,rm?
+fcvt.l.d    rv64 d           R     opcode=0x53 funct3=7 funct7=0x61 rs2=2            rd,fs1,rm?
+fcvt.lu.d   rv64 d           R     opcode=0x53 funct3=7 funct7=0x61 rs2=3            rd,fs1,rm?
+fcvt.d.l    rv64 d           R     opcode=0x53 funct3=7 funct7=0x69 rs2=2            fd,rs1,rm?
+fcvt.d.lu   rv64 d           R     opcode=0x53 funct3=7 funct7=0x69 rs2=3            fd,rs1,rm?
+fmv.x.d     rv64 d           R     opcode=0x53 funct3=0 funct7=0x71 rs2=0            rd,fs1
+fmv.d.x     rv64 d           R     opcode=0x53 funct3=0 funct7=0x79 rs2=0            fd,rs1
+
+# C EXTENSION
+c.ld        rv64 zca         CL    op=0b00 funct3=3 layout=ld                        rs2p,cmem
+c.sd        rv64 zca         CS    op=0b00 funct3=7 layout=ld                        rs2p,cmem
+c.addiw     rv64 zca         CI    op=0b01 funct3=1 layout=imm6                      crd,cimm
+c.ldsp      rv64 zca         CI    op=0b10 funct3=3 layout=ldsp                      crd,cspmem
+c.sdsp      rv64 zca         CSS   op=0b10 funct3=7 layout=sdsp                      crs2,cspmem
+c.subw      rv64 zca         CA    op=0b01 funct6=0x27 funct2=0                      rs1p,rs2p
+c.addw      rv64 zca         CA    op=0b01 funct6=0x27 funct2=1                      rs1p,rs2p
+c.zext.w    rv64 zcb+zba     CA    op=0b01 funct6=0x27 funct2=3 rs2=4                rs1p
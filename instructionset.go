@@ -0,0 +1,376 @@
+package assembler
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// OperandKind is the kind of one operand in the schema of a registered instruction
+type OperandKind int
+
+const (
+	IntRegister    OperandKind = iota // x0-x31 or their ABI names
+	FloatRegister                     // f0-f31 or their ABI names
+	VectorRegister                    // v0-v31
+	Immediate                         // literal, constant or label
+	Memory                            // imm(rs1) or (rs1)
+)
+
+func (k OperandKind) String() string {
+	names := []string{"integer register", "float register", "vector register", "immediate", "memory operand"}
+	if int(k) < len(names) && k >= 0 {
+		return names[k]
+	}
+	return "Unknown"
+}
+
+// InstructionDef describes a mnemonic added with RegisterInstruction
+type InstructionDef struct {
+	Format   OpCode        // R, R4, I, S, B, U, J or A
+	Fields   []byte        // fixed fields in the order of the built-in tables: opcode, func3, func7 for R
+	Operands []OperandKind // checked before lexing when set
+	XLEN     int           // 32 or 64 to limit the instruction to that base, 0 for both
+}
+
+// registrableFormats gives the number of fixed fields of the formats RegisterInstruction
+// accepts, R takes an optional 4th one holding a fixed rs2
+var registrableFormats = map[OpCode]int{R: 3, R4: 3, I: 3, S: 2, B: 2, U: 1, J: 1, A: 3}
+
+// formOperand is one operand of an operandForm
+type formOperand struct {
+	name     string
+	kind     OperandKind
+	optional bool
+}
+
+// operandForm is one of the operand lists an instruction accepts
+type operandForm []formOperand
+
+// PseudoExpander rewrites the operands of a pseudo instruction into the lines replacing it,
+// or returns an error when they do not fit
+type PseudoExpander func(operands []string) ([]string, error)
+
+// pseudoHandler expands a whole line, mnemonic included
+type pseudoHandler func(lineParts []string) ([]string, error)
+
+// InstructionSet holds the mnemonics and pseudo instructions an Assembler accepts,
+// registering on it only affects the Assemblers using it
+type InstructionSet struct {
+	instructions     map[string]OpPair
+	instructionsRV32 map[string]OpPair
+	instructionsRV64 map[string]OpPair
+	operands         map[string][]operandForm
+	pseudos          map[string]pseudoHandler
+	pseudosRV64      map[string]pseudoHandler
+}
+
+// standardInstructions backs Preprocess and PreprocessLine, nothing registers on it
+var standardInstructions = NewInstructionSet()
+
+// NewInstructionSet returns a set holding the standard instructions and pseudo instructions
+func NewInstructionSet() *InstructionSet {
+	return &InstructionSet{
+		instructions:     maps.Clone(instructionToOpType),
+		instructionsRV32: maps.Clone(instructionToOpTypeRV32),
+		instructionsRV64: maps.Clone(instructionToOpTypeRV64),
+		operands:         maps.Clone(instructionOperands),
+		pseudos:          maps.Clone(pseudoToInstruction),
+		pseudosRV64:      maps.Clone(pseudoToInstructionRV64),
+	}
+}
+
+// RegisterInstruction adds the mnemonic name, encoded with the format encoder from the
+// fixed fields of def
+func (s *InstructionSet) RegisterInstruction(name string, def InstructionDef) error {
+	if err := s.checkNewName(name); err != nil {
+		return err
+	}
+	fields, ok := registrableFormats[def.Format]
+	if !ok {
+		return fmt.Errorf("instruction '%s': format %s cannot be registered", name, def.Format)
+	}
+	if len(def.Fields) != fields && !(def.Format == R && len(def.Fields) == fields+1) {
+		return fmt.Errorf("instruction '%s': format %s takes %d fixed fields, got %d", name, def.Format, fields, len(def.Fields))
+	}
+	if def.Fields[0] > 0x7F || def.Fields[0]&0b11 != 0b11 {
+		return fmt.Errorf("instruction '%s': opcode %#x is not a 32 bit major opcode", name, def.Fields[0])
+	}
+	for _, kind := range def.Operands {
+		if kind < IntRegister || kind > Memory {
+			return fmt.Errorf("instruction '%s': unknown operand kind %d", name, kind)
+		}
+	}
+
+	// rev8 style instructions get one encoding per base
+	table, conflicts := s.instructions, []map[string]OpPair{s.instructionsRV32, s.instructionsRV64}
+	switch def.XLEN {
+	case 0:
+	case 32:
+		table, conflicts = s.instructionsRV32, nil
+	case 64:
+		table, conflicts = s.instructionsRV64, nil
+	default:
+		return fmt.Errorf("instruction '%s': XLEN must be 0, 32 or 64, got %d", name, def.XLEN)
+	}
+	for _, other := range append(conflicts, table) {
+		if _, ok := other[name]; ok {
+			return errors.New("instruction '" + name + "' is already defined")
+		}
+	}
+	table[name] = OpPair{def.Format, append([]byte(nil), def.Fields...)}
+	if def.Operands != nil {
+		form := operandForm{}
+		for _, kind := range def.Operands {
+			form = append(form, formOperand{kind.String(), kind, false})
+		}
+		s.operands[name] = []operandForm{form}
+	}
+	return nil
+}
+
+// RegisterPseudo adds the pseudo instruction name, expand receives its comma separated
+// operands and returns the lines assembled in its place
+func (s *InstructionSet) RegisterPseudo(name string, expand PseudoExpander) error {
+	if err := s.checkNewName(name); err != nil {
+		return err
+	}
+	for _, table := range []map[string]OpPair{s.instructionsRV32, s.instructionsRV64} {
+		if _, ok := table[name]; ok {
+			return errors.New("instruction '" + name + "' is already defined")
+		}
+	}
+	if expand == nil {
+		return errors.New("pseudo instruction '" + name + "' has no expander")
+	}
+	s.pseudos[name] = func(lineParts []string) ([]string, error) {
+		var operands []string
+		for _, operand := range strings.Split(strings.Join(lineParts[1:], " "), ",") {
+			if operand = strings.TrimSpace(operand); operand != "" {
+				operands = append(operands, operand)
+			}
+		}
+		lines, err := expand(operands)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		return lines, nil
+	}
+	return nil
+}
+
+// checkNewName rejects the names that are not a single word, are common instructions
+// or pseudo instructions
+func (s *InstructionSet) checkNewName(name string) error {
+	if name == "" || strings.ContainsAny(name, " \t,#():") || name[0] == '.' {
+		return errors.New("invalid mnemonic '" + name + "'")
+	}
+	if _, ok := s.instructions[name]; ok {
+		return errors.New("instruction '" + name + "' is already defined")
+	}
+	if _, ok := s.pseudos[name]; ok {
+		return errors.New("pseudo instruction '" + name + "' is already defined")
+	}
+	if _, ok := s.pseudosRV64[name]; ok {
+		return errors.New("pseudo instruction '" + name + "' is already defined")
+	}
+	return nil
+}
+
+// lookup returns the encoding of an instruction for arch
+func (s *InstructionSet) lookup(name string, arch Arch) (OpPair, error) {
+	pair, isCommon := s.instructions[name]
+	rv32Type, isRV32 := s.instructionsRV32[name]
+	rv64Type, isRV64 := s.instructionsRV64[name]
+	switch {
+	case isCommon:
+	case isRV32 && arch.xlen() == 32:
+		pair = rv32Type
+	case isRV64 && arch == RV64:
+		pair = rv64Type
+	case isRV32:
+		return OpPair{}, errors.New("instruction '" + name + "' requires RV32")
+	case isRV64:
+		return OpPair{}, errors.New("instruction '" + name + "' requires RV64")
+	default:
+		return OpPair{}, errors.New("Unknown instruction type: '" + name + "'")
+	}
+	return pair, nil
+}
+
+// Lookup describes the instruction name as the Assembler encodes it for arch, in the terms
+// RegisterInstruction takes. Fields and Operands are copies, Operands is only set when
+// the instruction accepts a single list of operands
+func (s *InstructionSet) Lookup(name string, arch Arch) (InstructionDef, error) {
+	pair, err := s.lookup(name, arch)
+	if err != nil {
+		return InstructionDef{}, err
+	}
+	def := InstructionDef{Format: pair.opType, Fields: append([]byte(nil), pair.opByte...)}
+	if _, isCommon := s.instructions[name]; !isCommon {
+		def.XLEN = arch.xlen()
+	}
+	if forms := s.operands[name]; len(forms) == 1 {
+		for _, operand := range forms[0] {
+			if operand.optional || operand.kind > Memory {
+				def.Operands = nil
+				break
+			}
+			def.Operands = append(def.Operands, operand.kind)
+		}
+	}
+	return def, nil
+}
+
+// IsPseudo reports whether the Assemblers using s expand name on arch, a pseudo instruction
+// such as li or an instruction with pseudo forms such as the jalr a0 of jalr
+func (s *InstructionSet) IsPseudo(name string, arch Arch) bool {
+	if _, ok := s.pseudosRV64[name]; ok && arch == RV64 {
+		return true
+	}
+	_, ok := s.pseudos[name]
+	return ok
+}
+
+// LookupInstruction describes the standard instruction name for arch, see InstructionSet.Lookup
+func LookupInstruction(name string, arch Arch) (InstructionDef, error) {
+	return standardInstructions.Lookup(name, arch)
+}
+
+// IsPseudoInstruction reports whether name is a standard pseudo instruction on arch
+func IsPseudoInstruction(name string, arch Arch) bool {
+	return standardInstructions.IsPseudo(name, arch)
+}
+
+// checkOperands matches the operands of an instruction against the forms it accepts
+func (s *InstructionSet) checkOperands(name string, operands []string) error {
+	forms, ok := s.operands[name]
+	if !ok {
+		return nil
+	}
+	var values []string
+	for _, operand := range operands {
+		if operand = strings.TrimSpace(operand); operand != "" {
+			values = append(values, operand)
+		}
+	}
+	_, err := matchForms(name, forms, values)
+	return err
+}
+
+// matchForms returns the index of the first form values fill, or an error explaining
+// why none does
+func matchForms(name string, forms []operandForm, values []string) (int, error) {
+	for i, form := range forms {
+		if form.matches(values) {
+			return i, nil
+		}
+	}
+	if len(forms) == 1 {
+		return 0, forms[0].mismatch(name, values)
+	}
+	expected := make([]string, len(forms))
+	for i, form := range forms {
+		expected[i] = form.String()
+	}
+	return 0, fmt.Errorf("instruction '%s' expects %s, got %s", name, strings.Join(expected, " or "), strings.Join(values, ", "))
+}
+
+// matches reports whether values fill the form, skipping the optional operands left out
+func (f operandForm) matches(values []string) bool {
+	if len(f) == 0 {
+		return len(values) == 0
+	}
+	switch f[0].kind {
+	case vtypeOperand:
+		return len(values) > 0
+	case registerListOperand:
+		if len(values) == 0 || !strings.HasPrefix(values[0], "{") {
+			return false
+		}
+		for i, value := range values {
+			if strings.HasSuffix(value, "}") {
+				return f[1:].matches(values[i+1:])
+			}
+		}
+		return false
+	}
+	if len(values) > 0 && operandMatches(f[0].kind, values[0]) && f[1:].matches(values[1:]) {
+		return true
+	}
+	return f[0].optional && f[1:].matches(values)
+}
+
+// mismatch explains why values do not fill the form
+func (f operandForm) mismatch(name string, values []string) error {
+	required, variadic := 0, false
+	for _, operand := range f {
+		if !operand.optional {
+			required++
+		}
+		variadic = variadic || operand.kind == vtypeOperand || operand.kind == registerListOperand
+	}
+	switch {
+	case variadic:
+	case required == len(f) && len(values) != required:
+		return fmt.Errorf("instruction '%s' expects %d operands, got %d", name, required, len(values))
+	case len(values) < required || len(values) > len(f):
+		return fmt.Errorf("instruction '%s' expects %d to %d operands, got %d", name, required, len(f), len(values))
+	}
+	for i, operand := range f {
+		if i >= len(values) || operand.kind == vtypeOperand || operand.kind == registerListOperand {
+			break
+		}
+		if !operandMatches(operand.kind, values[i]) {
+			return fmt.Errorf("operand %d of '%s' expects %s, got %s", i+1, name, operand.kind, values[i])
+		}
+	}
+	return fmt.Errorf("instruction '%s' expects %s, got %s", name, f, strings.Join(values, ", "))
+}
+
+// String lists the operand names of the form, the optional ones in brackets
+func (f operandForm) String() string {
+	if len(f) == 0 {
+		return "no operands"
+	}
+	names := make([]string, len(f))
+	for i, operand := range f {
+		names[i] = operand.name
+		if operand.optional {
+			names[i] = "[" + operand.name + "]"
+		}
+	}
+	return strings.Join(names, ", ")
+}
+
+func operandMatches(kind OperandKind, value string) bool {
+	isRegister := func(value string) bool {
+		_, intErr := matchTokenValid(value)
+		_, floatErr := matchFloatRegisterValid(value)
+		_, vectorErr := matchVectorRegisterValid(value)
+		return intErr == nil || floatErr == nil || vectorErr == nil
+	}
+	switch kind {
+	case IntRegister:
+		_, err := matchTokenValid(value)
+		return err == nil
+	case FloatRegister:
+		_, err := matchFloatRegisterValid(value)
+		return err == nil
+	case VectorRegister:
+		_, err := matchVectorRegisterValid(value)
+		return err == nil
+	case Immediate:
+		return !isRegister(value)
+	case Memory:
+		// the offset may be a %lo(symbol) of its own
+		open := strings.LastIndex(value, "(")
+		if open == -1 || !strings.HasSuffix(value, ")") {
+			return false
+		}
+		_, err := matchTokenValid(value[open+1 : len(value)-1])
+		return err == nil && !isRegister(value[:open])
+	}
+	return false
+}
@@ -0,0 +1,239 @@
+package assembler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// vendorAssembler returns an Assembler for arch accepting a few vendor instructions
+func vendorAssembler(t *testing.T, arch Arch) *Assembler {
+	t.Helper()
+	set := NewInstructionSet()
+	defs := map[string]InstructionDef{
+		"vendor.add": {Format: R, Fields: []byte{0x0B, 0, 0}},
+		"vendor.ld":  {Format: I, Fields: []byte{0x0B, 4, 0}, Operands: []OperandKind{IntRegister, Memory}},
+		"vendor.sd":  {Format: S, Fields: []byte{0x2B, 3}, Operands: []OperandKind{IntRegister, Memory}},
+		"vendor.lui": {Format: U, Fields: []byte{0x5B}, Operands: []OperandKind{IntRegister, Immediate}},
+		"vendor.w":   {Format: R, Fields: []byte{0x7B, 1, 0}, XLEN: 64},
+	}
+	for name, def := range defs {
+		if err := set.RegisterInstruction(name, def); err != nil {
+			t.Fatalf("RegisterInstruction(%q) error = %v", name, err)
+		}
+	}
+	err := set.RegisterPseudo("vendor.clr", func(operands []string) ([]string, error) {
+		if len(operands) != 1 {
+			return nil, errors.New("expects 1 operand")
+		}
+		return []string{"vendor.add " + operands[0] + ", x0, x0"}, nil
+	})
+	if err != nil {
+		t.Fatalf("RegisterPseudo() error = %v", err)
+	}
+	a := &Assembler{Arch: arch, Instructions: set}
+	a.compilation.labelPositions = map[string]int{}
+	return a
+}
+
+func TestInstructionSet_RegisteredInstructions(t *testing.T) {
+	tests := []struct {
+		line    string
+		arch    Arch
+		want    uint32
+		wantErr string
+	}{
+		{line: "vendor.add a0, a1, a2", want: 0x00C5850B},
+		{line: "vendor.ld a0, 8(a1)", want: 0x0085C50B},
+		{line: "vendor.sd a0, 8(a1)", want: 0x00A5B42B},
+		{line: "vendor.lui a0, 0x12345", want: 0x1234555B},
+		{line: "vendor.clr a0", want: 0x0000050B},
+		{line: "vendor.w a0, a1, a2", arch: RV64, want: 0x00C5957B},
+		{line: "vendor.w a0, a1, a2", wantErr: "requires RV64"},
+		{line: "vendor.ld a0, a1, 8", wantErr: "expects 2 operands"},
+		{line: "vendor.ld a0, 8(fa1)", wantErr: "expects memory operand"},
+		{line: "vendor.sd fa0, 8(a1)", wantErr: "expects integer register"},
+		{line: "vendor.lui a0, a1", wantErr: "expects immediate"},
+		{line: "vendor.clr a0, a1", wantErr: "vendor.clr: expects 1 operand"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arch.String()+" "+tt.line, func(t *testing.T) {
+			code, err := vendorAssembler(t, tt.arch).AssembleLine(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("AssembleLine(%q) error = %v, want %q", tt.line, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AssembleLine(%q) error = %v", tt.line, err)
+			}
+			if len(code) != 4 || binary.LittleEndian.Uint32(code) != tt.want {
+				t.Errorf("AssembleLine(%q) = % x, want %#08x", tt.line, code, tt.want)
+			}
+		})
+	}
+}
+
+func TestInstructionSet_Isolation(t *testing.T) {
+	vendorAssembler(t, RV32)
+	for _, line := range []string{"vendor.add a0, a1, a2", "vendor.clr a0"} {
+		if _, err := assembleLineWords(line); err == nil {
+			t.Errorf("AssembleLine(%q) on the standard set succeeded, want an error", line)
+		}
+		if got := PreprocessLine(line); len(got) != 1 || got[0] != line {
+			t.Errorf("PreprocessLine(%q) = %v, want it unchanged", line, got)
+		}
+	}
+}
+
+func TestInstructionSet_RegisterInstruction(t *testing.T) {
+	tests := []struct {
+		name    string
+		def     InstructionDef
+		wantErr bool
+	}{
+		{"vendor.op", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}}, false},
+		{"vendor.op", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0, 5}}, false},
+		{"vendor.op", InstructionDef{Format: R4, Fields: []byte{0x43, 0, 3}}, false},
+		{"vendor.op", InstructionDef{Format: B, Fields: []byte{0x63, 2}}, false},
+		{"vendor.op", InstructionDef{Format: J, Fields: []byte{0x7B}, XLEN: 32}, false},
+		{"rev8", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}, XLEN: 64}, true},
+		{"add", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}}, true},
+		{"mv", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}}, true},
+		{"c.jal", InstructionDef{Format: J, Fields: []byte{0x6F}, XLEN: 32}, true},
+		{"vendor op", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}}, true},
+		{".vendor", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}}, true},
+		{"", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}}, true},
+		{"vendor.op", InstructionDef{Format: CI, Fields: []byte{0x01, 0, 0}}, true},
+		{"vendor.op", InstructionDef{Format: I, Fields: []byte{0x0B, 0}}, true},
+		{"vendor.op", InstructionDef{Format: U, Fields: []byte{0x08}}, true},
+		{"vendor.op", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}, XLEN: 16}, true},
+		{"vendor.op", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}, Operands: []OperandKind{OperandKind(9)}}, true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s %v", tt.name, tt.def.Format, tt.def.Fields), func(t *testing.T) {
+			err := NewInstructionSet().RegisterInstruction(tt.name, tt.def)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("RegisterInstruction(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstructionSet_RegisterTwice(t *testing.T) {
+	set := NewInstructionSet()
+	rv32 := InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}, XLEN: 32}
+	rv64 := InstructionDef{Format: R, Fields: []byte{0x0B, 1, 0}, XLEN: 64}
+	if err := set.RegisterInstruction("vendor.op", rv32); err != nil {
+		t.Fatalf("RegisterInstruction(RV32) error = %v", err)
+	}
+	if err := set.RegisterInstruction("vendor.op", rv64); err != nil {
+		t.Fatalf("RegisterInstruction(RV64) error = %v", err)
+	}
+	if err := set.RegisterInstruction("vendor.op", rv32); err == nil {
+		t.Error("RegisterInstruction(RV32) twice succeeded, want an error")
+	}
+	if err := set.RegisterInstruction("vendor.op", InstructionDef{Format: R, Fields: []byte{0x0B, 0, 0}}); err == nil {
+		t.Error("RegisterInstruction() over the RV32 and RV64 forms succeeded, want an error")
+	}
+	if err := set.RegisterPseudo("vendor.op", func([]string) ([]string, error) { return nil, nil }); err == nil {
+		t.Error("RegisterPseudo() over an instruction succeeded, want an error")
+	}
+	if err := set.RegisterPseudo("li", func([]string) ([]string, error) { return nil, nil }); err == nil {
+		t.Error("RegisterPseudo(li) succeeded, want an error")
+	}
+	if err := set.RegisterPseudo("vendor.nop", nil); err == nil {
+		t.Error("RegisterPseudo() without expander succeeded, want an error")
+	}
+}
+
+func TestInstructionSet_StandardOperands(t *testing.T) {
+	tests := []struct {
+		line    string
+		wantErr string
+	}{
+		{line: "jalr a0, a1", wantErr: "expects rs or rd, rs, offset or rd, mem"},
+		{line: "ecall a0", wantErr: "expects 0 operands"},
+		{line: "addi a0, a1, a2", wantErr: "operand 3 of 'addi' expects immediate"},
+		{line: "fadd.s fa0, fa1", wantErr: "expects 3 to 4 operands"},
+		{line: "vadd.vv v1, v2, a0", wantErr: "operand 3 of 'vadd.vv' expects vector register"},
+		{line: "lw a0, a1", wantErr: "'lw' expects rd, mem or rd, symbol"},
+		{line: "lr.w a0, a1", wantErr: "operand 2 of 'lr.w' expects memory operand"},
+		{line: "cm.push ra, -16", wantErr: "expects rlist, stackadj"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.line, func(t *testing.T) {
+			_, err := assembleLineWords(tt.line)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("AssembleLine(%q) error = %v, want %q", tt.line, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInstructionSet_Lookup(t *testing.T) {
+	tests := []struct {
+		name    string
+		arch    Arch
+		want    InstructionDef
+		wantErr string
+	}{
+		{name: "add", arch: RV32, want: InstructionDef{Format: R, Fields: []byte{0x33, 0, 0}, Operands: []OperandKind{IntRegister, IntRegister, IntRegister}}},
+		{name: "ld", arch: RV64, want: InstructionDef{Format: I, Fields: []byte{0x03, 3, 0}, Operands: []OperandKind{IntRegister, Memory}, XLEN: 64}},
+		{name: "jalr", arch: RV32, want: InstructionDef{Format: I, Fields: []byte{0x67, 0, 0}}},
+		{name: "ld", arch: RV32, wantErr: "instruction 'ld' requires RV64"},
+		{name: "vendor.add", arch: RV32, wantErr: "Unknown instruction type: 'vendor.add'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arch.String()+" "+tt.name, func(t *testing.T) {
+			got, err := LookupInstruction(tt.name, tt.arch)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("LookupInstruction(%q) error = %v, want %q", tt.name, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LookupInstruction(%q) error = %v", tt.name, err)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("LookupInstruction(%q) = %+v, want %+v", tt.name, got, tt.want)
+			}
+			// the returned fields are a copy, the standard table stays untouched
+			got.Fields[0] = 0
+			if again, _ := LookupInstruction(tt.name, tt.arch); again.Fields[0] == 0 {
+				t.Errorf("LookupInstruction(%q) shares its fields with the instruction table", tt.name)
+			}
+		})
+	}
+	vendor, err := vendorAssembler(t, RV64).Instructions.Lookup("vendor.w", RV64)
+	if err != nil || vendor.XLEN != 64 {
+		t.Errorf("Lookup(vendor.w) = %+v, %v, want XLEN 64", vendor, err)
+	}
+}
+
+func TestInstructionSet_IsPseudo(t *testing.T) {
+	set := vendorAssembler(t, RV32).Instructions
+	tests := []struct {
+		name string
+		arch Arch
+		want bool
+	}{
+		{"li", RV32, true},
+		{"sext.w", RV64, true},
+		{"sext.w", RV32, false},
+		{"vendor.clr", RV32, true},
+		{"vendor.add", RV32, false},
+	}
+	for _, tt := range tests {
+		if got := set.IsPseudo(tt.name, tt.arch); got != tt.want {
+			t.Errorf("IsPseudo(%q, %s) = %v, want %v", tt.name, tt.arch, got, tt.want)
+		}
+	}
+	if IsPseudoInstruction("vendor.clr", RV32) {
+		t.Errorf("IsPseudoInstruction(vendor.clr) = true on the standard set")
+	}
+}
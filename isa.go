@@ -0,0 +1,264 @@
+package assembler
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ISA is the set of extensions enabled by an -march string such as rv32imac_zicsr_zifencei
+type ISA struct {
+	Arch       Arch
+	extensions map[string]bool
+}
+
+// extensionVersions lists the supported extensions with the version written to .riscv.attributes
+var extensionVersions = map[string]string{
+	"i": "2p1", "e": "2p0", "m": "2p0", "a": "2p1", "f": "2p2", "d": "2p2", "c": "2p0", "v": "1p0",
+	"zicsr": "2p0", "zifencei": "2p0", "zihintpause": "2p0",
+	"zicbom": "1p0", "zicboz": "1p0", "zicbop": "1p0", "zicond": "1p0",
+	"zca": "1p0", "zcf": "1p0", "zcd": "1p0", "zcb": "1p0", "zcmp": "1p0",
+	"zba": "1p0", "zbb": "1p0", "zbc": "1p0", "zbs": "1p0", "zbkb": "1p0", "zbkc": "1p0",
+	"zknd": "1p0", "zkne": "1p0", "zknh": "1p0",
+}
+
+// extensionImplies lists the extensions another one brings along
+var extensionImplies = map[string][]string{
+	"g":    {"i", "m", "a", "f", "d", "zicsr", "zifencei"},
+	"f":    {"zicsr"},
+	"d":    {"f"},
+	"c":    {"zca"},
+	"zcf":  {"zca", "f"},
+	"zcd":  {"zca", "d"},
+	"zcb":  {"zca"},
+	"zcmp": {"zca"},
+}
+
+// instructionExtensions maps each instruction to the extensions providing it, from the
+// extensions column of instructions.spec, the instructions missing from it belong to the
+// base integer ISA
+var instructionExtensions = standardSpec.extensions()
+
+// extensionPrerequisites lists the instructions needing a second extension on top of
+// the ones they are listed under in instructionExtensions
+var extensionPrerequisites = standardSpec.prerequisites()
+
+// ParseISA reads an -march string: the base rv32i, rv32e, rv32g, rv64i or rv64g, the single
+// letter extensions then the multi-letter ones separated by underscores, version numbers
+// such as the 2p1 of rv32i2p1 are accepted and ignored
+func ParseISA(march string) (ISA, error) {
+	str := strings.ToLower(strings.TrimSpace(march))
+	isa := ISA{extensions: map[string]bool{}}
+	switch {
+	case strings.HasPrefix(str, "rv32"):
+		isa.Arch = RV32
+	case strings.HasPrefix(str, "rv64"):
+		isa.Arch = RV64
+	default:
+		return ISA{}, errors.New("-march " + march + ": must start with rv32 or rv64")
+	}
+	parts := strings.Split(str[4:], "_")
+	letters := parts[0]
+	if letters == "" || !strings.ContainsRune("ieg", rune(letters[0])) {
+		return ISA{}, errors.New("-march " + march + ": the base ISA must be i, e or g")
+	}
+	if err := isa.enableLetters(letters); err != nil {
+		return ISA{}, fmt.Errorf("-march %s: %w", march, err)
+	}
+	for _, ext := range parts[1:] {
+		var err error
+		switch {
+		case ext == "":
+			err = errors.New("empty extension")
+		case ext[0] == 'z':
+			err = isa.enable(trimVersion(ext))
+		default:
+			// normalized strings separate the single letters too: rv32i2p1_m2p0
+			err = isa.enableLetters(ext)
+		}
+		if err != nil {
+			return ISA{}, fmt.Errorf("-march %s: %w", march, err)
+		}
+	}
+	if isa.extensions["e"] {
+		if isa.Arch != RV32 || isa.extensions["i"] {
+			return ISA{}, errors.New("-march " + march + ": the e base is only available as rv32e")
+		}
+		isa.Arch = RV32E
+	}
+	// only a literal c brings the compressed float loads and stores, zca alone leaves them out
+	if isa.extensions["c"] && isa.extensions["d"] {
+		isa.extensions["zcd"] = true
+	}
+	if isa.extensions["c"] && isa.extensions["f"] && isa.Arch != RV64 {
+		isa.extensions["zcf"] = true
+	}
+	if isa.extensions["zcf"] && isa.Arch == RV64 {
+		return ISA{}, errors.New("-march " + march + ": zcf is only available on rv32")
+	}
+	if isa.extensions["zcmp"] && isa.extensions["zcd"] {
+		// cm.push and cm.pop take the encodings of c.fsdsp and c.fldsp, which only a
+		// written c or zcd brings along with d
+		return ISA{}, errors.New("-march " + march + ": zcmp cannot be combined with zcd, c with d")
+	}
+	return isa, nil
+}
+
+// enableLetters enables a run of single letter extensions such as imac or m2p0a2p1
+func (isa *ISA) enableLetters(letters string) error {
+	for len(letters) > 0 {
+		ext := letters[:1]
+		letters = skipVersion(letters[1:])
+		if err := isa.enable(ext); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// skipVersion drops the version number such as 2p1 at the start of str
+func skipVersion(str string) string {
+	str = strings.TrimLeft(str, "0123456789")
+	if len(str) > 1 && str[0] == 'p' && str[1] >= '0' && str[1] <= '9' {
+		str = strings.TrimLeft(str[1:], "0123456789")
+	}
+	return str
+}
+
+// trimVersion drops the version number such as 2p0 at the end of a multi-letter extension
+func trimVersion(ext string) string {
+	trimmed := strings.TrimRight(ext, "0123456789")
+	if trimmed == ext {
+		return ext
+	}
+	if minor, ok := strings.CutSuffix(trimmed, "p"); ok {
+		if major := strings.TrimRight(minor, "0123456789"); len(major) < len(minor) {
+			return major
+		}
+	}
+	return trimmed
+}
+
+// enable adds ext and the extensions it implies
+func (isa *ISA) enable(ext string) error {
+	implied, isGroup := extensionImplies[ext]
+	if _, ok := extensionVersions[ext]; !ok && !isGroup {
+		return errors.New("unsupported extension '" + ext + "'")
+	}
+	if _, ok := extensionVersions[ext]; ok {
+		isa.extensions[ext] = true
+	}
+	for _, dep := range implied {
+		if err := isa.enable(dep); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Has reports whether the extension is enabled
+func (isa ISA) Has(ext string) bool {
+	return isa.extensions[ext]
+}
+
+// checkInstruction returns an error when name needs an extension that is not enabled
+func (isa ISA) checkInstruction(name string) error {
+	return isa.checkInstructionAs(name, name)
+}
+
+// checkInstructionAs checks the extensions of the instruction name and reports the missing
+// one under the name the source wrote, the pseudo instruction expanding to it
+func (isa ISA) checkInstructionAs(name string, written string) error {
+	exts := instructionExtensions[name]
+	if len(exts) > 0 && !isa.hasAny(exts) {
+		return fmt.Errorf("instruction '%s' requires extension %s", written, extensionNames(exts))
+	}
+	if ext, ok := extensionPrerequisites[name]; ok && !isa.Has(ext) {
+		return fmt.Errorf("instruction '%s' requires extension %s", written, extensionNames([]string{ext}))
+	}
+	return nil
+}
+
+func (isa ISA) hasAny(exts []string) bool {
+	for _, ext := range exts {
+		if isa.Has(ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// extensionNames spells extensions the way the specification does: M, Zicsr, Zbb or Zbkb
+func extensionNames(exts []string) string {
+	names := make([]string, len(exts))
+	for i, ext := range exts {
+		names[i] = strings.ToUpper(ext[:1]) + ext[1:]
+	}
+	return strings.Join(names, " or ")
+}
+
+// String returns the canonical arch string with versions, as written to the
+// Tag_RISCV_arch attribute: rv32i2p1_m2p0_a2p1_c2p0_zicsr2p0
+func (isa ISA) String() string {
+	var b strings.Builder
+	if isa.Arch == RV64 {
+		b.WriteString("rv64")
+	} else {
+		b.WriteString("rv32")
+	}
+	var multi []string
+	for ext := range isa.extensions {
+		if len(ext) > 1 {
+			multi = append(multi, ext)
+		}
+	}
+	// single letters come in the canonical order, the multi-letter extensions are
+	// grouped by the letter following the z then sorted alphabetically
+	const order = "iemafdqlcbkjtpvh"
+	first := true
+	for _, letter := range order {
+		ext := string(letter)
+		if isa.extensions[ext] {
+			if !first {
+				b.WriteByte('_')
+			}
+			b.WriteString(ext + extensionVersions[ext])
+			first = false
+		}
+	}
+	sort.Slice(multi, func(i, j int) bool {
+		ci, cj := strings.IndexByte(order, multi[i][1]), strings.IndexByte(order, multi[j][1])
+		if ci != cj {
+			return ci < cj
+		}
+		return multi[i] < multi[j]
+	})
+	for _, ext := range multi {
+		b.WriteString("_" + ext + extensionVersions[ext])
+	}
+	return b.String()
+}
+
+// riscvAttributes returns the content of the .riscv.attributes section holding the arch string
+func (isa ISA) riscvAttributes() []byte {
+	const (
+		tagFile       = 1
+		tagRISCVArch  = 5
+		vendor        = "riscv\x00"
+		subsectionLen = 4 + len(vendor)
+	)
+	arch := isa.String()
+	attributes := append([]byte{tagRISCVArch}, arch...)
+	attributes = append(attributes, 0)
+	// Tag_File, its length including the tag and the length itself, then the attributes
+	file := []byte{tagFile}
+	file = binary.LittleEndian.AppendUint32(file, uint32(1+4+len(attributes)))
+	file = append(file, attributes...)
+
+	section := []byte{'A'}
+	section = binary.LittleEndian.AppendUint32(section, uint32(subsectionLen+len(file)))
+	section = append(section, vendor...)
+	return append(section, file...)
+}
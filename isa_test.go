@@ -0,0 +1,230 @@
+package assembler
+
+import (
+	"bytes"
+	"debug/elf"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseISA(t *testing.T) {
+	tests := []struct {
+		march   string
+		arch    Arch
+		want    string
+		wantErr bool
+	}{
+		{march: "rv32i", arch: RV32, want: "rv32i2p1"},
+		{march: "rv32imac_zicsr_zifencei", arch: RV32, want: "rv32i2p1_m2p0_a2p1_c2p0_zicsr2p0_zifencei2p0_zca1p0"},
+		{march: "RV32IMAC_Zicsr", arch: RV32, want: "rv32i2p1_m2p0_a2p1_c2p0_zicsr2p0_zca1p0"},
+		{march: "rv64gc", arch: RV64, want: "rv64i2p1_m2p0_a2p1_f2p2_d2p2_c2p0_zicsr2p0_zifencei2p0_zca1p0_zcd1p0"},
+		{march: "rv32if", arch: RV32, want: "rv32i2p1_f2p2_zicsr2p0"},
+		{march: "rv32e_zcb_zcmp", arch: RV32E, want: "rv32e2p0_zca1p0_zcb1p0_zcmp1p0"},
+		{march: "rv32i2p1_m2p0_zicsr2p0", arch: RV32, want: "rv32i2p1_m2p0_zicsr2p0"},
+		{march: "rv64i_zknh_zbkb_zicbop_zba", arch: RV64, want: "rv64i2p1_zicbop1p0_zba1p0_zbkb1p0_zknh1p0"},
+		{march: "rv32i_zca", arch: RV32, want: "rv32i2p1_zca1p0"},
+		{march: "rv32imafd_zcb", arch: RV32, want: "rv32i2p1_m2p0_a2p1_f2p2_d2p2_zicsr2p0_zca1p0_zcb1p0"},
+		{march: "rv128i", wantErr: true},
+		{march: "rv32", wantErr: true},
+		{march: "rv32mi", wantErr: true},
+		{march: "rv32ix", wantErr: true},
+		{march: "rv32i_zfoo", wantErr: true},
+		{march: "rv32i_", wantErr: true},
+		{march: "rv64e", wantErr: true},
+		{march: "rv32ie", wantErr: true},
+		{march: "rv32imafdc_zcmp", wantErr: true},
+		{march: "rv64gc_zcmp", wantErr: true},
+		{march: "rv64if_zcf", wantErr: true},
+		{march: "rv32imafd_zcd_zcmp", wantErr: true},
+		{march: "rv32imafd_zcmp", arch: RV32, want: "rv32i2p1_m2p0_a2p1_f2p2_d2p2_zicsr2p0_zca1p0_zcmp1p0"},
+		{march: "rv32imafc_zcmp", arch: RV32, want: "rv32i2p1_m2p0_a2p1_f2p2_c2p0_zicsr2p0_zca1p0_zcf1p0_zcmp1p0"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.march, func(t *testing.T) {
+			got, err := ParseISA(tt.march)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseISA() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if got.Arch != tt.arch {
+				t.Errorf("ParseISA() arch = %v, want %v", got.Arch, tt.arch)
+			}
+			if got.String() != tt.want {
+				t.Errorf("ParseISA() = %v, want %v", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestISA_checkInstruction(t *testing.T) {
+	tests := []struct {
+		march string
+		name  string
+		want  string
+	}{
+		{"rv32i", "addi", ""},
+		{"rv32i", "ecall", ""},
+		{"rv32i", "mul", "instruction 'mul' requires extension M"},
+		{"rv32im", "mul", ""},
+		{"rv32i", "amoadd.w.aqrl", "instruction 'amoadd.w.aqrl' requires extension A"},
+		{"rv32ia", "amoadd.w.aqrl", ""},
+		{"rv32i", "csrrw", "instruction 'csrrw' requires extension Zicsr"},
+		{"rv32if", "csrrw", ""},
+		{"rv32i", "fence.i", "instruction 'fence.i' requires extension Zifencei"},
+		{"rv32i", "rev8", "instruction 'rev8' requires extension Zbb or Zbkb"},
+		{"rv32i_zbkb", "rev8", ""},
+		{"rv32i_zbkb", "clz", "instruction 'clz' requires extension Zbb"},
+		{"rv32ic", "c.flw", "instruction 'c.flw' requires extension Zcf"},
+		{"rv32if_zcb", "c.flw", "instruction 'c.flw' requires extension Zcf"},
+		{"rv32if_zcf", "c.flw", ""},
+		{"rv32ifd_zcb", "c.fsdsp", "instruction 'c.fsdsp' requires extension Zcd"},
+		{"rv32ifc", "c.flw", ""},
+		{"rv32i_zcb", "c.mul", "instruction 'c.mul' requires extension M"},
+		{"rv32im_zcb", "c.mul", ""},
+		{"rv32i_zcb", "c.add", ""},
+		{"rv32imafd_zcmp", "c.fsdsp", "instruction 'c.fsdsp' requires extension Zcd"},
+		{"rv32imafd_zcmp", "cm.push", ""},
+		{"rv64i", "vadd.vv", "instruction 'vadd.vv' requires extension V"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.march+" "+tt.name, func(t *testing.T) {
+			isa, err := ParseISA(tt.march)
+			if err != nil {
+				t.Fatalf("ParseISA() error = %v", err)
+			}
+			got := ""
+			if err := isa.checkInstruction(tt.name); err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("checkInstruction() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_March(t *testing.T) {
+	tests := []struct {
+		march   string
+		line    string
+		want    []byte
+		wantErr string
+	}{
+		{march: "rv32i", line: "add a0, a1, a2", want: []byte{0x33, 0x85, 0xC5, 0x00}},
+		{march: "rv32i", line: "mul a0, a1, a2", wantErr: "requires extension M"},
+		{march: "rv32im", line: "mul a0, a1, a2", want: []byte{0x33, 0x85, 0xC5, 0x02}},
+		{march: "rv32i", line: "csrr a0, mstatus", wantErr: "'csrr' requires extension Zicsr"},
+		{march: "rv32i_zicsr", line: "csrr a0, mstatus", want: []byte{0x73, 0x25, 0x00, 0x30}},
+		{march: "rv32i", line: "fmv.s fa0, fa1", wantErr: "'fmv.s' requires extension F"},
+		{march: "rv32if", line: "fneg.d fa0, fa1", wantErr: "'fneg.d' requires extension D"},
+		{march: "rv32if", line: "fneg.s fa0, fa1", want: []byte{0x53, 0x95, 0xB5, 0x20}},
+		{march: "rv64i", line: "ld a0, 0(a1)", want: []byte{0x03, 0xB5, 0x05, 0x00}},
+		{march: "rv32i", line: "ld a0, 0(a1)", wantErr: "requires RV64"},
+		{march: "rv32e", line: "add a6, a0, a1", wantErr: "not available on RV32E"},
+		{march: "rv32imafd_zcmp", line: "cm.push {ra}, -16", want: []byte{0x42, 0xB8}},
+		{march: "rv32imafd_zcmp", line: "c.fsdsp fa0, 8(sp)", wantErr: "requires extension Zcd"},
+		{march: "rv32x", line: "add a0, a1, a2", wantErr: "-march rv32x"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.march+" "+tt.line, func(t *testing.T) {
+			a := &Assembler{March: tt.march}
+			got, err := a.AssembleLine(tt.line)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("AssembleLine(%q) error = %v, want %q", tt.line, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("AssembleLine(%q) error = %v", tt.line, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssembleLine(%q) = % x, want % x", tt.line, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAssembler_AssembleLine_MarchCompilation(t *testing.T) {
+	// the compilation sees the same ISA whether the code comes from Assemble or AssembleLine
+	a := &Assembler{March: "rv64imac"}
+	if _, err := a.AssembleLine("nop"); err != nil {
+		t.Fatalf("AssembleLine() error = %v", err)
+	}
+	if a.compilation.arch != RV64 || a.compilation.isa == nil || a.compilation.isa.String() != "rv64i2p1_m2p0_a2p1_c2p0_zca1p0" {
+		t.Errorf("compilation arch = %v, isa = %v", a.compilation.arch, a.compilation.isa)
+	}
+}
+
+func TestAssembler_AssembleLine_MarchCompression(t *testing.T) {
+	// without C in the ISA string .option rvc has no compressed form to pick
+	tests := []struct {
+		march string
+		want  []byte
+	}{
+		{"rv32i", []byte{0x13, 0x05, 0x15, 0x00}},
+		{"rv32ic", []byte{0x05, 0x05}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.march, func(t *testing.T) {
+			a := &Assembler{March: tt.march, RVC: true}
+			got, err := a.AssembleLine("addi a0, a0, 1")
+			if err != nil {
+				t.Fatalf("AssembleLine() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("AssembleLine() = % x, want % x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBuildELFFile_Attributes(t *testing.T) {
+	for _, march := range []string{"rv32imac_zicsr_zifencei", "rv64gc_zba"} {
+		t.Run(march, func(t *testing.T) {
+			isa, err := ParseISA(march)
+			if err != nil {
+				t.Fatalf("ParseISA() error = %v", err)
+			}
+			program := Program{
+				machinecode: []byte{0x13, 0x00, 0x00, 0x00},
+				arch:        isa.Arch,
+				isa:         &isa,
+			}
+			f, err := elf.NewFile(bytes.NewReader(*BuildELFFile(program)))
+			if err != nil {
+				t.Fatalf("elf.NewFile() error = %v", err)
+			}
+			section := f.Section(".riscv.attributes")
+			if section == nil {
+				t.Fatalf("BuildELFFile() has no .riscv.attributes section")
+			}
+			if section.Type != shtRISCVAttributes {
+				t.Errorf("section type = %#x, want %#x", uint32(section.Type), shtRISCVAttributes)
+			}
+			data, err := section.Data()
+			if err != nil {
+				t.Fatalf("section.Data() error = %v", err)
+			}
+			if !bytes.Equal(data, isa.riscvAttributes()) {
+				t.Errorf("section data = % x, want % x", data, isa.riscvAttributes())
+			}
+			if !bytes.Contains(data, []byte(isa.String()+"\x00")) {
+				t.Errorf("section data does not hold the arch string %s", isa.String())
+			}
+		})
+	}
+}
+
+func TestISA_riscvAttributes(t *testing.T) {
+	isa, _ := ParseISA("rv32im")
+	want := []byte{'A', 0x1E, 0x00, 0x00, 0x00, 'r', 'i', 's', 'c', 'v', 0x00,
+		0x01, 0x14, 0x00, 0x00, 0x00, 0x05}
+	want = append(want, "rv32i2p1_m2p0\x00"...)
+	if got := isa.riscvAttributes(); !bytes.Equal(got, want) {
+		t.Errorf("riscvAttributes() = % x, want % x", got, want)
+	}
+}
@@ -2,24 +2,75 @@ package assembler
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"math"
+	"math/bits"
 	"os"
 	"strconv"
 	"strings"
 )
 
-func Preprocess(file *os.File) []string {
-
+// Preprocess expands the standard pseudo instructions of file, a pseudo instruction
+// with invalid operands is kept as written for the assembler to report while a
+// malformed .equ or .set stops it with an error
+func Preprocess(file *os.File, arch_optional ...Arch) ([]string, error) {
+	arch := RV32
+	if len(arch_optional) > 0 {
+		arch = arch_optional[0]
+	}
 	var result []string = make([]string, 0)
+	equates := equates{}
 	scanner := bufio.NewScanner(file)
+	lineNumber := 0
 	for scanner.Scan() {
-		line := scanner.Text()
-		result = append(result, PreprocessLine(line)...)
+		lineNumber++
+		line, err := equates.expand(scanner.Text())
+		if err != nil {
+			return result, errors.New("LINE " + strconv.Itoa(lineNumber) + " " + err.Error())
+		}
+		result = append(result, PreprocessLine(line, arch)...)
 	}
+	return result, nil
+}
+
+// PreprocessLine expands the standard pseudo instructions of line, a pseudo instruction
+// with invalid operands is kept as written for the assembler to report
+func PreprocessLine(line string, arch_optional ...Arch) []string {
+	arch := RV32
+	if len(arch_optional) > 0 {
+		arch = arch_optional[0]
+	}
+	result, _ := standardInstructions.preprocessLine(line, arch, nil)
 	return result
 }
 
-func PreprocessLine(line string) []string {
+// preprocess expands the pseudo instructions of file registered on s, isa is nil when
+// every extension is enabled
+func (s *InstructionSet) preprocess(file *os.File, arch Arch, isa *ISA) ([]string, error) {
+	var result []string = make([]string, 0)
+	equates := equates{}
+	scanner := bufio.NewScanner(file)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line, err := equates.expand(scanner.Text())
+		if err != nil {
+			return result, errors.New("LINE " + strconv.Itoa(lineNumber) + " " + err.Error())
+		}
+		lines, err := s.preprocessLine(line, arch, isa)
+		if err != nil {
+			return result, errors.New("LINE " + strconv.Itoa(lineNumber) + " " + err.Error())
+		}
+		result = append(result, lines...)
+	}
+	return result, nil
+}
+
+// preprocessLine strips the comments of line and expands it when it is a pseudo
+// instruction registered on s, the line stays as it is along with the error when the
+// operands do not fit
+func (s *InstructionSet) preprocessLine(line string, arch Arch, isa *ISA) ([]string, error) {
 	var result []string = []string{}
 	//prune comments
 	lineIndx := strings.Index(line, "#")
@@ -34,145 +85,273 @@ func PreprocessLine(line string) []string {
 	//prune empty lines
 	lineParts = removeEmptyStrings(lineParts)
 	if len(lineParts) == 0 {
-		return result
+		return result, nil
 	}
 
-	if res, ok := PseudoToInstruction[lineParts[0]]; ok {
-		var resArray []string = res(lineParts)
-		result = append(result, resArray...)
-	} else {
-		result = append(result, line)
+	// sext.b and the like are Zbb instructions only when an ISA string enables Zbb, the
+	// base sequences work everywhere else
+	if instructionPseudos[lineParts[0]] && isa != nil && isa.checkInstruction(lineParts[0]) == nil {
+		if _, err := s.lookup(lineParts[0], arch); err == nil {
+			return append(result, line), nil
+		}
 	}
-	return result
-}
 
-func handleMV(lineParts []string) []string {
-	if len(lineParts) < 3 {
-		return []string{"invalid mv instruction"}
+	handler, ok := s.pseudosRV64[lineParts[0]]
+	if !ok || arch != RV64 {
+		handler, ok = s.pseudos[lineParts[0]]
 	}
-
-	rd := strings.TrimSpace(lineParts[1])
-	rs := strings.TrimSpace(lineParts[2])
-
-	return []string{fmt.Sprintf("addi %s, %s, 0", rd, rs)}
-}
-
-func handleJ(lineParts []string) []string {
-	if len(lineParts) < 2 {
-		return []string{"invalid j instruction"}
+	if !ok {
+		return append(result, line), nil
 	}
-
-	target := strings.TrimSpace(lineParts[1])
-	return []string{fmt.Sprintf("jal x0, %s", target)}
+	lines, err := handler(lineParts)
+	if lines == nil {
+		// the operands already make up an instruction, or do not fit
+		return append(result, strings.Join(lineParts, " ")), err
+	}
+	if err == nil && isa != nil {
+		// fmv.s needs F, which is clearer than naming the fsgnj.s it expands to
+		for _, expanded := range lines {
+			if fields := strings.Fields(expanded); len(fields) > 0 {
+				if err := isa.checkInstructionAs(fields[0], lineParts[0]); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return lines, err
 }
 
-func handleJAL(lineParts []string) []string {
-	rg := strings.TrimSpace(lineParts[1])
-	if len(lineParts) < 3 {
-		return []string{fmt.Sprintf("jal x1, %s", rg)}
-	}
+// equates holds the symbols defined by .equ and .set, each one replaced by its
+// value in the lines following its definition
+type equates map[string]string
 
-	rd := strings.TrimSpace(lineParts[1])
-	rg = strings.TrimSpace(lineParts[2])
-	//rd already contains the necessary comma for %s
-	return []string{fmt.Sprintf("jal %s %s", rd, rg)}
+// expand returns line with the equates replaced, or an empty line when line defines one
+func (e equates) expand(line string) (string, error) {
+	code, _, _ := strings.Cut(line, "#")
+	directive, rest, _ := strings.Cut(strings.TrimSpace(strings.ReplaceAll(code, "\t", " ")), " ")
+	if directive != ".equ" && directive != ".set" {
+		// the mnemonic keeps its name even when an equate shares it
+		start := len(line) - len(strings.TrimLeft(line, " \t"))
+		end := start + strings.IndexAny(line[start:]+" ", " \t")
+		return line[:end] + e.replace(line[end:]), nil
+	}
+	name, value, ok := strings.Cut(rest, ",")
+	name, value = strings.TrimSpace(name), strings.TrimSpace(e.replace(value))
+	if !ok || name == "" {
+		return "", errors.New(directive + ": expected a name and a value")
+	}
+	if _, err := parseInt64Value(value); err != nil {
+		return "", fmt.Errorf("%s %s: %s is not a number", directive, name, value)
+	}
+	e[name] = value
+	return "", nil
 }
 
-func handleJR(lineParts []string) []string {
-	if len(lineParts) < 2 {
-		return []string{"invalid jr instruction"}
+// replace substitutes the equates in text, leaving the modifiers such as %hi,
+// numbers and strings alone
+func (e equates) replace(text string) string {
+	if len(e) == 0 {
+		return text
 	}
+	var b strings.Builder
+	for i := 0; i < len(text); {
+		c := text[i]
+		if c == '"' || c == '\'' {
+			end := i + 1
+			for end < len(text) && text[end] != c {
+				if text[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			end = min(end+1, len(text))
+			b.WriteString(text[i:end])
+			i = end
+			continue
+		}
+		if !isSymbolChar(c) {
+			b.WriteByte(c)
+			i++
+			continue
+		}
+		end := i
+		for end < len(text) && isSymbolChar(text[end]) {
+			end++
+		}
+		word := text[i:end]
+		if value, ok := e[word]; ok {
+			word = value
+		}
+		b.WriteString(word)
+		i = end
+	}
+	return b.String()
+}
 
-	rs := strings.TrimSpace(lineParts[1])
-	return []string{fmt.Sprintf("jalr x0, %s, 0", rs)}
+// isSymbolChar reports whether c belongs to a symbol, a number or a modifier
+func isSymbolChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.IndexByte("_.$%", c) >= 0
 }
 
-func handleADD(lineParts []string) []string {
-	rd := strings.TrimSpace(lineParts[1])
-	rs := strings.TrimSpace(lineParts[2])
-	if len(lineParts) < 4 {
-		//rd already contains the necessary comma for  %s
-		return []string{fmt.Sprintf("add %s %s %s", rd, rd, rs)}
-	}
-	val := strings.TrimSpace(lineParts[3])
-	//rd and rs already contain the necessary comma for %s
-	return []string{fmt.Sprintf("add %s %s %s", rd, rs, val)}
+// pseudoForm is one operand list a pseudo instruction accepts and the lines replacing it
+type pseudoForm struct {
+	operands operandForm
+	lines    []string                                  // %[1]s stands for the first operand, none keeps the line
+	expand   func(operands []string) ([]string, error) // builds the lines when set
 }
 
-func handleSUB(lineParts []string) []string {
-	rd := strings.TrimSpace(lineParts[1])
-	rs := strings.TrimSpace(lineParts[2])
-	if len(lineParts) < 4 {
-		//rd already contains the necessary comma for  %s
-		return []string{fmt.Sprintf("sub %s %s %s", rd, rd, rs)}
-	}
-	val := strings.TrimSpace(lineParts[3])
-	//rd and rs already contain the necessary comma for %s
-	return []string{fmt.Sprintf("sub %s %s %s", rd, rs, val)}
+// pseudoOperandKinds gives the kind of the operand names of the pseudo instruction forms
+var pseudoOperandKinds = map[string]OperandKind{
+	"rd": IntRegister, "rs": IntRegister, "rt": IntRegister, "rs1": IntRegister, "rs2": IntRegister,
+	"fd": FloatRegister, "fs": FloatRegister,
+	"imm": Immediate, "offset": Immediate, "symbol": Immediate, "csr": Immediate, "pred": Immediate, "succ": Immediate,
+	"mem": Memory,
 }
 
-func handleBLE(lineParts []string) []string {
-	if len(lineParts) < 4 {
-		return []string{"invalid ble instruction"}
+// form accepts the comma separated operand names of pseudoOperandKinds and expands them to lines
+func form(operands string, lines ...string) pseudoForm {
+	f := pseudoForm{lines: lines}
+	if operands == "" {
+		return f
 	}
+	for _, name := range strings.Split(operands, ",") {
+		kind, ok := pseudoOperandKinds[name]
+		if !ok {
+			panic("unknown pseudo instruction operand " + name)
+		}
+		f.operands = append(f.operands, formOperand{name, kind, false})
+	}
+	return f
+}
 
-	rs1 := strings.TrimSpace(lineParts[1])
-	rs2 := strings.TrimSpace(lineParts[2])
-	label := strings.TrimSpace(lineParts[3])
-
-	// ble is equivalent to bge with swapped operands
-	return []string{fmt.Sprintf("bge %s, %s, %s", rs2, rs1, label)}
+// expandForm is a form whose lines are computed by expand
+func expandForm(operands string, expand func(operands []string) ([]string, error)) pseudoForm {
+	f := form(operands)
+	f.expand = expand
+	return f
 }
 
-func handleLI(lineParts []string) []string {
-	if len(lineParts) < 3 {
-		return []string{"invalid li instruction"}
+// pseudo builds the handler of the pseudo instruction name from the forms it accepts
+func pseudo(name string, forms ...pseudoForm) pseudoHandler {
+	schemas := make([]operandForm, len(forms))
+	for i, f := range forms {
+		schemas[i] = f.operands
 	}
+	return func(lineParts []string) ([]string, error) {
+		operands := pseudoOperands(lineParts[1:])
+		i, err := matchForms(name, schemas, operands)
+		if err != nil {
+			return nil, err
+		}
+		if forms[i].expand != nil {
+			return forms[i].expand(operands)
+		}
+		if len(forms[i].lines) == 0 {
+			return nil, nil
+		}
+		args := make([]any, len(operands))
+		for j, operand := range operands {
+			args[j] = operand
+		}
+		lines := make([]string, len(forms[i].lines))
+		for j, line := range forms[i].lines {
+			lines[j] = fmt.Sprintf(line, args...)
+		}
+		return lines, nil
+	}
+}
 
-	rd := strings.TrimSpace(lineParts[1])
-	imm := strings.TrimSpace(lineParts[2])
+// pseudoOperands splits the operands of a pseudo instruction, separated by commas or spaces
+func pseudoOperands(parts []string) []string {
+	return strings.FieldsFunc(strings.Join(parts, " "), func(r rune) bool {
+		return r == ',' || r == ' '
+	})
+}
 
-	val, err := strconv.Atoi(imm)
+// handleLI loads imm into rd with a single addi or lui when it can, lui and addi
+// otherwise, symbols are left to %hi and %lo
+func handleLI(operands []string) ([]string, error) {
+	rd, imm := operands[0], operands[1]
+	val, err := parseInt64Value(imm)
 	if err != nil {
-		//imm may be a register
 		return []string{
 			fmt.Sprintf("lui %s, %%hi(%s)", rd, imm),
 			fmt.Sprintf("addi %s, %s, %%lo(%s)", rd, rd, imm),
-		}
+		}, nil
 	}
-
-	if val >= -2048 && val < 2048 {
-		return []string{fmt.Sprintf("addi %s, x0, %s", rd, imm)}
+	if val < math.MinInt32 || val > math.MaxUint32 {
+		return nil, fmt.Errorf("li: %s does not fit in 32 bits", imm)
 	}
+	return materialize32(rd, int64(int32(val)), "addi"), nil
+}
 
-	return []string{
-		fmt.Sprintf("lui %s, %%hi(%s)", rd, imm),
-		fmt.Sprintf("addi %s, %s, %%lo(%s)", rd, rd, imm),
+// handleLI64 materialises 64-bit constants for RV64, anything that is not a
+// plain number is left to handleLI
+func handleLI64(operands []string) ([]string, error) {
+	val, err := parseInt64Value(operands[1])
+	if err != nil {
+		return handleLI(operands)
 	}
+	return materializeRV64(operands[0], val), nil
 }
 
-func handleLA(lineParts []string) []string {
-	if len(lineParts) < 3 {
-		return []string{"invalid la instruction"}
+// symbolAccess expands the load or store op of a symbol to an auipc of its address
+// and op with %pcrel_lo, based on the loaded register or on the temporary rt of the
+// stores and the float loads, a bare number stays an address relative to x0
+func symbolAccess(op string) func(operands []string) ([]string, error) {
+	return func(operands []string) ([]string, error) {
+		reg, symbol, rt := operands[0], operands[1], operands[0]
+		if len(operands) > 2 {
+			rt = operands[2]
+		}
+		if _, err := parseInt64Value(symbol); err == nil {
+			return nil, nil
+		}
+		return []string{
+			fmt.Sprintf("auipc %s, %%pcrel_hi(%s)", rt, symbol),
+			fmt.Sprintf("%s %s, %%pcrel_lo(%s)(%s)", op, reg, symbol, rt),
+		}, nil
 	}
+}
 
-	rd := strings.TrimSpace(lineParts[1])
-	symbol := strings.TrimSpace(lineParts[2])
-
-	return []string{
-		fmt.Sprintf("auipc %s, %%pcrel_hi(%s)", rd, symbol),
-		fmt.Sprintf("addi %s, %s, %%pcrel_lo(%s)", rd, rd, symbol),
+// materialize32 emits the lui/addi sequence loading the 32 bit val into rd, the
+// lower part is added with addi, or addiw to sign extend the sum on RV64
+func materialize32(rd string, val int64, addi string) []string {
+	// a negative lo12 borrows from the upper part, hence the 0x800 rounding
+	hi20 := ((val + 0x800) >> 12) & 0xFFFFF
+	lo12 := signExtend(val, 12)
+	if hi20 == 0 {
+		return []string{fmt.Sprintf("addi %s, x0, %d", rd, lo12)}
+	}
+	result := []string{fmt.Sprintf("lui %s, 0x%X", rd, hi20)}
+	if lo12 != 0 {
+		result = append(result, fmt.Sprintf("%s %s, %s, %d", addi, rd, rd, lo12))
 	}
+	return result
 }
 
-func handleRET(lineParts []string) []string {
-	return []string{
-		"jalr x0, 0(x1)",
+// materializeRV64 emits the lui/addiw/slli/addi sequence loading val into rd
+func materializeRV64(rd string, val int64) []string {
+	if val >= math.MinInt32 && val <= math.MaxInt32 {
+		return materialize32(rd, val, "addiw")
 	}
-}
 
-func handleNOP(lineParts []string) []string {
-	return []string{
-		"addi x0, x0, 0",
+	// peel off the low 12 bits, load the rest shifted down and shift it back in place
+	lo12 := signExtend(val, 12)
+	hi52 := (val + 0x800) >> 12
+	shift := 12 + bits.TrailingZeros64(uint64(hi52))
+	hi52 = signExtend(hi52>>(shift-12), 64-shift)
+
+	result := materializeRV64(rd, hi52)
+	result = append(result, fmt.Sprintf("slli %s, %s, %d", rd, rd, shift))
+	if lo12 != 0 {
+		result = append(result, fmt.Sprintf("addi %s, %s, %d", rd, rd, lo12))
 	}
+	return result
+}
+
+// signExtend sign extends the low width bits of val
+func signExtend(val int64, width int) int64 {
+	shift := 64 - width
+	return val << shift >> shift
 }
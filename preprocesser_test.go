@@ -20,24 +20,28 @@ func TestPreprocess(t *testing.T) {
 		expected []string
 	}{
 		{"mv valid", "mv x1 x2", []string{"addi x1, x2, 0"}},
-		{"mv invalid", "mv x1", []string{"invalid mv instruction"}},
+		{"mv invalid", "mv x1", []string{"mv x1"}},
 		{"j valid", "j label", []string{"jal x0, label"}},
-		{"j invalid", "j", []string{"invalid j instruction"}},
+		{"j invalid", "j", []string{"j"}},
 		{"jal with 2 args", "jal x1, label", []string{"jal x1, label"}},
 		{"jal with 1 arg", "jal label", []string{"jal x1, label"}},
 		{"jr valid", "jr x5", []string{"jalr x0, x5, 0"}},
-		{"jr invalid", "jr", []string{"invalid jr instruction"}},
+		{"jr invalid", "jr", []string{"jr"}},
 		{"add with 3 args", "add x1 x2 x3", []string{"add x1 x2 x3"}},
-		{"add with 2 args", "add x1 x2", []string{"add x1 x1 x2"}},
+		{"add with 2 args", "add x1 x2", []string{"add x1, x1, x2"}},
 		{"sub with 3 args", "sub x1 x2 x3", []string{"sub x1 x2 x3"}},
-		{"sub with 2 args", "sub x1 x2", []string{"sub x1 x1 x2"}},
+		{"sub with 2 args", "sub x1 x2", []string{"sub x1, x1, x2"}},
 		{"ble valid", "ble x1 x2 label", []string{"bge x2, x1, label"}},
-		{"ble invalid", "ble x1", []string{"invalid ble instruction"}},
+		{"ble invalid", "ble x1", []string{"ble x1"}},
 		{"li small imm", "li x1 42", []string{"addi x1, x0, 42"}},
-		{"li large imm", "li x1 5000", []string{
-			"lui x1, %hi(5000)",
-			"addi x1, x1, %lo(5000)",
-		}},
+		{"li large imm", "li x1 5000", []string{"lui x1, 0x1", "addi x1, x1, 904"}},
+		{"li hex imm", "li x1 0x7FF", []string{"addi x1, x0, 2047"}},
+		{"li negative imm", "li x1 -2048", []string{"addi x1, x0, -2048"}},
+		{"li upper only", "li x1 0x12345000", []string{"lui x1, 0x12345"}},
+		{"li with carry", "li x1 0x12345FFF", []string{"lui x1, 0x12346", "addi x1, x1, -1"}},
+		{"li with carry into bit 31", "li x1 0x7FFFF800", []string{"lui x1, 0x80000", "addi x1, x1, -2048"}},
+		{"li unsigned 32 bit", "li x1 0xFFFFFFFF", []string{"addi x1, x0, -1"}},
+		{"li too large", "li x1 0x100000000", []string{"li x1 0x100000000"}},
 		{"li symbol", "li x1 symbol", []string{
 			"lui x1, %hi(symbol)",
 			"addi x1, x1, %lo(symbol)",
@@ -46,11 +50,62 @@ func TestPreprocess(t *testing.T) {
 			"auipc x1, %pcrel_hi(symbol)",
 			"addi x1, x1, %pcrel_lo(symbol)",
 		}},
-		{"la invalid", "la x1", []string{"invalid la instruction"}},
+		{"la invalid", "la x1", []string{"la x1"}},
 		{"ret", "ret", []string{"jalr x0, 0(x1)"}},
 		{"nop", "nop", []string{"addi x0, x0, 0"}},
+		{"fmv.s valid", "fmv.s fa0, fa1", []string{"fsgnj.s fa0, fa1, fa1"}},
+		{"fabs.s valid", "fabs.s fa0, fa1", []string{"fsgnjx.s fa0, fa1, fa1"}},
+		{"fneg.s valid", "fneg.s fa0, fa1", []string{"fsgnjn.s fa0, fa1, fa1"}},
+		{"fneg.s invalid", "fneg.s fa0", []string{"fneg.s fa0"}},
+		{"fmv.d valid", "fmv.d fa0, fa1", []string{"fsgnj.d fa0, fa1, fa1"}},
+		{"csrr valid", "csrr a0, mstatus", []string{"csrrs a0, mstatus, x0"}},
+		{"csrr invalid", "csrr a0", []string{"csrr a0"}},
+		{"csrw valid", "csrw mtvec, t0", []string{"csrrw x0, mtvec, t0"}},
+		{"csrsi valid", "csrsi mstatus, 8", []string{"csrrsi x0, mstatus, 8"}},
+		{"rdcycle valid", "rdcycle a0", []string{"csrrs a0, cycle, x0"}},
+		{"rdtime invalid", "rdtime", []string{"rdtime"}},
+		{"not", "not a0, a1", []string{"xori a0, a1, -1"}},
+		{"neg", "neg a0, a1", []string{"sub a0, x0, a1"}},
+		{"seqz", "seqz a0, a1", []string{"sltiu a0, a1, 1"}},
+		{"snez", "snez a0, a1", []string{"sltu a0, x0, a1"}},
+		{"sltz", "sltz a0, a1", []string{"slt a0, a1, x0"}},
+		{"sgtz", "sgtz a0, a1", []string{"slt a0, x0, a1"}},
+		{"zext.b", "zext.b a0, a1", []string{"andi a0, a1, 255"}},
+		{"sext.b without Zbb", "sext.b a0, a1", []string{"slli a0, a1, 24", "srai a0, a0, 24"}},
+		{"beqz", "beqz a0, loop", []string{"beq a0, x0, loop"}},
+		{"bnez", "bnez a0, loop", []string{"bne a0, x0, loop"}},
+		{"blez", "blez a0, loop", []string{"bge x0, a0, loop"}},
+		{"bgez", "bgez a0, loop", []string{"bge a0, x0, loop"}},
+		{"bltz", "bltz a0, loop", []string{"blt a0, x0, loop"}},
+		{"bgtz", "bgtz a0, loop", []string{"blt x0, a0, loop"}},
+		{"bgt", "bgt a0, a1, loop", []string{"blt a1, a0, loop"}},
+		{"bgtu", "bgtu a0, a1, loop", []string{"bltu a1, a0, loop"}},
+		{"bleu", "bleu a0, a1, loop", []string{"bgeu a1, a0, loop"}},
+		{"beqz invalid", "beqz a0, a1", []string{"beqz a0, a1"}},
+		{"jalr with 1 arg", "jalr a0", []string{"jalr x1, a0, 0"}},
+		{"jalr with 3 args", "jalr x0, a0, 4", []string{"jalr x0, a0, 4"}},
+		{"call", "call func", []string{"auipc x1, %pcrel_hi(func)", "jalr x1, x1, %pcrel_lo(func)"}},
+		{"call with link register", "call t0, func", []string{"auipc t0, %pcrel_hi(func)", "jalr t0, t0, %pcrel_lo(func)"}},
+		{"tail", "tail func", []string{"auipc x6, %pcrel_hi(func)", "jalr x0, x6, %pcrel_lo(func)"}},
+		{"lla", "lla a0, symbol", []string{"auipc a0, %pcrel_hi(symbol)", "addi a0, a0, %pcrel_lo(symbol)"}},
+		{"lw symbol", "lw a0, counter", []string{"auipc a0, %pcrel_hi(counter)", "lw a0, %pcrel_lo(counter)(a0)"}},
+		{"lw memory", "lw a0, 8(a1)", []string{"lw a0, 8(a1)"}},
+		{"lw number", "lw a0, 8", []string{"lw a0, 8"}},
+		{"sb symbol", "sb a0, counter, t0", []string{"auipc t0, %pcrel_hi(counter)", "sb a0, %pcrel_lo(counter)(t0)"}},
+		{"sw without temporary", "sw a0, counter", []string{"sw a0, counter"}},
+		{"fld symbol", "fld fa0, counter, t0", []string{"auipc t0, %pcrel_hi(counter)", "fld fa0, %pcrel_lo(counter)(t0)"}},
+		{"fsw symbol", "fsw fa0, counter, t0", []string{"auipc t0, %pcrel_hi(counter)", "fsw fa0, %pcrel_lo(counter)(t0)"}},
+		{"fence", "fence", []string{"fence iorw, iorw"}},
+		{"fence with sets", "fence rw, w", []string{"fence rw, w"}},
+		{"fscsr", "fscsr a0", []string{"csrrw x0, fcsr, a0"}},
+		{"fscsr with rd", "fscsr a0, a1", []string{"csrrw a0, fcsr, a1"}},
+		{"frrm", "frrm a0", []string{"csrrs a0, frm, x0"}},
+		{"fsflagsi", "fsflagsi 3", []string{"csrrwi x0, fflags, 3"}},
 		{"comments", "add x1 x2 x3 # this is a comment", []string{"add x1 x2 x3"}},
 		{"empty line", "", []string{}},
+		{"equ", ".equ SIZE, 0x12345FFF\nli a0, SIZE", []string{"lui a0, 0x12346", "addi a0, a0, -1"}},
+		{"set from equ", ".equ BASE, 16 # bytes\n.set OFF, BASE\nlw a0, OFF(sp)", []string{"lw a0, 16(sp)"}},
+		{"equ in a string", ".equ N, 1\n.string \"N\"", []string{".string \"N\""}},
 		{"tab characters", "add\tx1\tx2\tx3", []string{"add x1 x2 x3"}},
 	}
 
@@ -58,10 +113,79 @@ func TestPreprocess(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			file := fileFromString(tt.input)
 			defer os.Remove(file.Name())
-			got := Preprocess(file)
+			got, err := Preprocess(file)
+			if err != nil {
+				t.Fatalf("Preprocess() error = %v", err)
+			}
 			if !reflect.DeepEqual(got, tt.expected) {
 				t.Errorf("Preprocess() = %v, want %v", got, tt.expected)
 			}
 		})
 	}
 }
+
+func TestPreprocess_EquateErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		wantErr string
+	}{
+		{"equ not a number", ".equ N, x1", "LINE 1 .equ N: x1 is not a number"},
+		{"set without value", "nop\n.set N", "LINE 2 .set: expected a name and a value"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := fileFromString(tt.input)
+			defer os.Remove(file.Name())
+			_, err := Preprocess(file)
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("Preprocess() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPreprocessLineRV64(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{"li small imm", "li a0, 2047", []string{"addi a0, x0, 2047"}},
+		{"li upper only", "li a0, 0x12345000", []string{"lui a0, 0x12345"}},
+		{"li 32 bit with carry", "li a0, 0x12345FFF", []string{"lui a0, 0x12346", "addiw a0, a0, -1"}},
+		{"li int32 max", "li a0, 0x7FFFFFFF", []string{"lui a0, 0x80000", "addiw a0, a0, -1"}},
+		{"li 2^31", "li a0, 0x80000000", []string{"addi a0, x0, 1", "slli a0, a0, 31"}},
+		{"li 2^32", "li a0, 0x100000000", []string{"addi a0, x0, 1", "slli a0, a0, 32"}},
+		{"li int64 min", "li a0, 0x8000000000000000", []string{"addi a0, x0, -1", "slli a0, a0, 63"}},
+		{"li 64 bit", "li a0, 0x123456789ABCDEF0", []string{
+			"lui a0, 0x247",
+			"addiw a0, a0, -1875",
+			"slli a0, a0, 14",
+			"addi a0, a0, -947",
+			"slli a0, a0, 12",
+			"addi a0, a0, 1511",
+			"slli a0, a0, 13",
+			"addi a0, a0, -272",
+		}},
+		{"li symbol", "li a0 symbol", []string{
+			"lui a0, %hi(symbol)",
+			"addi a0, a0, %lo(symbol)",
+		}},
+		{"sext.w", "sext.w a0, a1", []string{"addiw a0, a1, 0"}},
+		{"zext.w without Zba", "zext.w a0, a1", []string{"slli a0, a1, 32", "srli a0, a0, 32"}},
+		{"ld symbol", "ld a0, counter", []string{"auipc a0, %pcrel_hi(counter)", "ld a0, %pcrel_lo(counter)(a0)"}},
+		{"sd symbol", "sd a0, counter, t0", []string{"auipc t0, %pcrel_hi(counter)", "sd a0, %pcrel_lo(counter)(t0)"}},
+		{"negw", "negw a0, a1", []string{"subw a0, x0, a1"}},
+		{"rv32 pseudo still available", "mv a0 a1", []string{"addi a0, a1, 0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := PreprocessLine(tt.input, RV64)
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("PreprocessLine() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
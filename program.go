@@ -6,5 +6,7 @@ type Program struct {
 	constants            []byte
 	strings              []byte
 	entrypoint           [4]byte
+	arch                 Arch
+	isa                  *ISA
 	compilationVariables *Compilation
 }
@@ -0,0 +1,634 @@
+package assembler
+
+import (
+	_ "embed"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// instructionSpec lists the standard instructions, the mnemonic tables, the operand
+// checks, the extension of each instruction and Decode are all built from it
+//
+//go:embed instructions.spec
+var instructionSpec string
+
+// standardSpec is instructions.spec parsed, a malformed row panics when the package loads
+var standardSpec = mustParseSpec(instructionSpec)
+
+// specField is a fixed field of a format, listed in the order its encoder reads the opByte
+type specField struct {
+	name     string
+	lo, size int  // instruction bits holding the field, size 0 when it only steers the encoder
+	optional bool // the opByte ends before the field when a row leaves it out
+}
+
+// specFormats lists the fixed fields of each format
+var specFormats = map[OpCode][]specField{
+	R:     {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"funct7", 25, 7, false}, {"rs2", 20, 5, true}},
+	R4:    {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"fmt", 25, 2, false}},
+	I:     {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"funct7", 25, 7, false}},
+	S:     {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}},
+	B:     {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}},
+	U:     {{"opcode", 0, 7, false}},
+	J:     {{"opcode", 0, 7, false}},
+	A:     {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"funct7", 25, 7, false}},
+	CSR:   {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}},
+	SYS:   {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"funct7", 25, 7, false}, {"rs2", 20, 5, true}},
+	FENCE: {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"fm", 28, 4, false}, {"pred", 24, 4, true}, {"succ", 20, 4, true}},
+	BS:    {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"funct5", 25, 5, false}},
+	VSET:  {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"bits31_30", 30, 2, false}},
+	VMEM:  {{"opcode", 0, 7, false}, {"width", 12, 3, false}},
+	V:     {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"funct6", 26, 6, false}},
+	CMO:   {{"opcode", 0, 7, false}, {"funct3", 12, 3, false}, {"funct", 20, 5, false}},
+	CR:    {{"op", 0, 2, false}, {"funct4", 12, 4, false}},
+	CI:    {{"op", 0, 2, false}, {"funct3", 13, 3, false}, {"layout", 0, 0, false}},
+	CSS:   {{"op", 0, 2, false}, {"funct3", 13, 3, false}, {"layout", 0, 0, false}},
+	CIW:   {{"op", 0, 2, false}, {"funct3", 13, 3, false}, {"layout", 0, 0, false}},
+	CL:    {{"op", 0, 2, false}, {"funct3", 13, 3, false}, {"layout", 0, 0, false}, {"bits12_6", 6, 7, true}},
+	CS:    {{"op", 0, 2, false}, {"funct3", 13, 3, false}, {"layout", 0, 0, false}, {"bits12_6", 6, 7, true}},
+	CB:    {{"op", 0, 2, false}, {"funct3", 13, 3, false}, {"layout", 0, 0, false}, {"funct2", 10, 2, true}},
+	CJ:    {{"op", 0, 2, false}, {"funct3", 13, 3, false}, {"layout", 0, 0, false}},
+	CA:    {{"op", 0, 2, false}, {"funct6", 10, 6, false}, {"funct2", 5, 2, false}, {"rs2", 2, 3, true}},
+	CMPP:  {{"op", 0, 2, false}, {"funct3", 13, 3, false}, {"funct5", 8, 5, false}},
+	CMMV:  {{"op", 0, 2, false}, {"funct6", 10, 6, false}, {"funct2", 5, 2, false}},
+}
+
+// specLayouts maps the layout field values to the compressed immediate layouts
+var specLayouts = map[string]int{
+	"imm6": cImm6, "shamt": cShamt, "lui": cLui, "addi16sp": cAddi16sp, "lwsp": cLwsp,
+	"ldsp": cLdsp, "swsp": cSwsp, "sdsp": cSdsp, "addi4spn": cAddi4spn, "lw": cLw, "ld": cLd,
+	"branch": cBranch, "jump": cJump, "lbu": cLbu, "lh": cLh,
+}
+
+// operand kinds of the standard instructions that RegisterInstruction does not take
+const (
+	vtypeOperand        OperandKind = iota + 100 // e32, m1, ta, ma: every remaining operand
+	registerListOperand                          // {ra, s0-s3}, split on its commas
+)
+
+// specOperand describes an operand name of instructions.spec
+type specOperand struct {
+	kind   OperandKind
+	bits   uint32                                           // instruction bits the operand fills
+	layout bool                                             // the compressed immediate layout of the row fills more bits
+	fixed  uint32                                           // value of bits for the operands naming one register
+	text   func(row *specRow, word uint32, xlen int) string // the operand decoded, "" leaves it out
+}
+
+// specOperands lists the operand names of instructions.spec
+var specOperands = map[string]specOperand{
+	"rd":  intRegisterOperand(7, 5),
+	"rs1": intRegisterOperand(15, 5),
+	"rs2": intRegisterOperand(20, 5),
+	"fd":  floatRegisterOperand(7, 5),
+	"fs1": floatRegisterOperand(15, 5),
+	"fs2": floatRegisterOperand(20, 5),
+	"fs3": floatRegisterOperand(27, 5),
+	"vd":  vectorRegisterOperand(7),
+	"vs1": vectorRegisterOperand(15),
+	"vs2": vectorRegisterOperand(20),
+	"vs3": vectorRegisterOperand(7),
+	"vm": {Immediate, bitMask(25, 1), false, 0, func(_ *specRow, word uint32, _ int) string {
+		if field(word, 25, 1) == 0 {
+			return "v0.t"
+		}
+		return ""
+	}},
+	"rm": {Immediate, bitMask(12, 3), false, 0, func(_ *specRow, word uint32, _ int) string {
+		for name, mode := range roundingModes {
+			if mode == int(field(word, 12, 3)) && name != "dyn" {
+				return name
+			}
+		}
+		return ""
+	}},
+	"imm12":  immediateOperand(bitMask(20, 12), func(word uint32) int64 { return signedField(word, 20, 12) }),
+	"shamt":  immediateOperand(bitMask(20, 6), func(word uint32) int64 { return int64(field(word, 20, 6)) }),
+	"shamtw": immediateOperand(bitMask(20, 5), func(word uint32) int64 { return int64(field(word, 20, 5)) }),
+	"imm20":  immediateOperand(bitMask(12, 20), func(word uint32) int64 { return int64(field(word, 12, 20)) }),
+	"bimm":   immediateOperand(bitMask(7, 5)|bitMask(25, 7), branchOffset),
+	"jimm":   immediateOperand(bitMask(12, 20), jumpOffset),
+	"zimm":   immediateOperand(bitMask(15, 5), func(word uint32) int64 { return int64(field(word, 15, 5)) }),
+	"uimm5":  immediateOperand(bitMask(15, 5), func(word uint32) int64 { return int64(field(word, 15, 5)) }),
+	"simm5":  immediateOperand(bitMask(15, 5), func(word uint32) int64 { return signedField(word, 15, 5) }),
+	"bs":     immediateOperand(bitMask(30, 2), func(word uint32) int64 { return int64(field(word, 30, 2)) }),
+	"csr": {Immediate, bitMask(20, 12), false, 0, func(_ *specRow, word uint32, _ int) string {
+		return csrName(int(field(word, 20, 12)))
+	}},
+	"pred": {Immediate, bitMask(24, 4), false, 0, func(_ *specRow, word uint32, _ int) string { return fenceSet(field(word, 24, 4)) }},
+	"succ": {Immediate, bitMask(20, 4), false, 0, func(_ *specRow, word uint32, _ int) string { return fenceSet(field(word, 20, 4)) }},
+	"mem": {Memory, bitMask(15, 17), false, 0, func(_ *specRow, word uint32, _ int) string {
+		return memoryText(signedField(word, 20, 12), intRegisterNames[field(word, 15, 5)])
+	}},
+	"smem": {Memory, bitMask(7, 5) | bitMask(15, 5) | bitMask(25, 7), false, 0, func(_ *specRow, word uint32, _ int) string {
+		offset := signedField(word, 25, 7)<<5 | int64(field(word, 7, 5))
+		return memoryText(offset, intRegisterNames[field(word, 15, 5)])
+	}},
+	"amem": {Memory, bitMask(15, 5), false, 0, func(_ *specRow, word uint32, _ int) string {
+		return "(" + intRegisterNames[field(word, 15, 5)] + ")"
+	}},
+	"pmem": {Memory, bitMask(15, 5) | bitMask(25, 7), false, 0, func(_ *specRow, word uint32, _ int) string {
+		return memoryText(signedField(word, 25, 7)<<5, intRegisterNames[field(word, 15, 5)])
+	}},
+	"vtypei11": {vtypeOperand, bitMask(20, 11), false, 0, func(_ *specRow, word uint32, _ int) string { return vtypeText(field(word, 20, 11)) }},
+	"vtypei10": {vtypeOperand, bitMask(20, 10), false, 0, func(_ *specRow, word uint32, _ int) string { return vtypeText(field(word, 20, 10)) }},
+
+	// compressed operands, the primed rd', rs1' and rs2' registers are x8-x15
+	"crd":   intRegisterOperand(7, 5),
+	"crs2":  intRegisterOperand(2, 5),
+	"cfd":   floatRegisterOperand(7, 5),
+	"cfs2":  floatRegisterOperand(2, 5),
+	"rs1p":  intRegisterOperand(7, 3),
+	"rs2p":  intRegisterOperand(2, 3),
+	"frs2p": floatRegisterOperand(2, 3),
+	"r1s":   savedRegisterOperand(7),
+	"r2s":   savedRegisterOperand(2),
+	"csp":   {IntRegister, bitMask(7, 5), false, 2 << 7, func(*specRow, uint32, int) string { return "sp" }},
+	"sp":    {IntRegister, 0, false, 0, func(*specRow, uint32, int) string { return "sp" }},
+	"cimm": {Immediate, 0, true, 0, func(row *specRow, word uint32, _ int) string {
+		imm := row.layoutValue(word)
+		if row.pair.opByte[2] == cLui {
+			// c.lui takes the upper 20 bits like lui
+			return strconv.FormatInt(imm>>12&0xFFFFF, 10)
+		}
+		return strconv.FormatInt(imm, 10)
+	}},
+	"cmem": {Memory, bitMask(7, 3), true, 0, func(row *specRow, word uint32, _ int) string {
+		return memoryText(row.layoutValue(word), intRegisterNames[8+field(word, 7, 3)])
+	}},
+	"cspmem": {Memory, 0, true, 0, func(row *specRow, word uint32, _ int) string {
+		return memoryText(row.layoutValue(word), "sp")
+	}},
+	"rlist": {registerListOperand, bitMask(4, 4), false, 0, func(_ *specRow, word uint32, _ int) string {
+		rlist := field(word, 4, 4)
+		switch {
+		case rlist == 4:
+			return "{ra}"
+		case rlist == 5:
+			return "{ra, s0}"
+		case rlist == 15:
+			return "{ra, s0-s11}"
+		}
+		return fmt.Sprintf("{ra, s0-s%d}", rlist-5)
+	}},
+	"stackadj": {Immediate, bitMask(2, 2), false, 0, func(row *specRow, word uint32, xlen int) string {
+		registers := int(field(word, 4, 4)) - 3
+		if registers == 12 {
+			registers = 13
+		}
+		adj := (registers*xlen/8+15)&^15 + int(field(word, 2, 2))*16
+		if row.pair.opByte[2] == 0b11000 {
+			adj = -adj
+		}
+		return strconv.Itoa(adj)
+	}},
+}
+
+// specRow is one instruction of instructions.spec
+type specRow struct {
+	name        string
+	xlen        int      // 32 or 64 when the instruction only exists on that base, 0 otherwise
+	extensions  []string // any of them provides the instruction, nil for the base ISA
+	requires    string   // extension needed on top of extensions, "" when none
+	pair        OpPair
+	forms       [][]string // operand names of each accepted form, optional ones end with ?
+	match, mask uint32     // bits fixed by the encoding and their value
+}
+
+// xlenOr returns the base of the row, or xlen for the rows of both bases
+func (r *specRow) xlenOr(xlen int) int {
+	if r.xlen != 0 {
+		return r.xlen
+	}
+	return xlen
+}
+
+// layoutValue extracts the immediate of a compressed row from its layout
+func (r *specRow) layoutValue(word uint32) int64 {
+	layout := compressedLayouts[r.pair.opByte[2]]
+	var imm uint32
+	top := 0
+	for i, bit := range layout.bits {
+		if bit < 0 {
+			continue
+		}
+		imm |= field(word, 12-i, 1) << bit
+		top = max(top, bit)
+	}
+	if layout.signed {
+		return signedField(imm, 0, top+1)
+	}
+	return int64(imm)
+}
+
+// isaSpec holds the parsed rows of instructions.spec
+type isaSpec struct {
+	rows []*specRow
+}
+
+func mustParseSpec(text string) *isaSpec {
+	spec, err := parseSpec(text)
+	if err != nil {
+		panic(err)
+	}
+	return spec
+}
+
+// parseSpec reads the rows of an instruction specification, see instructions.spec
+func parseSpec(text string) (*isaSpec, error) {
+	formats := map[string]OpCode{}
+	for format := range specFormats {
+		formats[format.String()] = format
+	}
+	spec := &isaSpec{}
+	defined := map[string]int{}
+	for n, line := range strings.Split(text, "\n") {
+		if comment := strings.Index(line, "#"); comment != -1 {
+			line = line[:comment]
+		}
+		cols := strings.Fields(line)
+		if len(cols) == 0 {
+			continue
+		}
+		row, err := parseSpecRow(cols, formats)
+		if err != nil {
+			return nil, fmt.Errorf("instructions.spec line %d: %w", n+1, err)
+		}
+		rows := []*specRow{row}
+		if row.pair.opType == A {
+			rows = append(rows, row.withOrderings()...)
+		}
+		for _, row := range rows {
+			// a mnemonic is defined once per base
+			if xlen, ok := defined[row.name]; ok && (xlen == 0 || row.xlen == 0 || xlen == row.xlen) {
+				return nil, fmt.Errorf("instructions.spec line %d: '%s' is already defined", n+1, row.name)
+			}
+			defined[row.name] = row.xlen
+			spec.rows = append(spec.rows, row)
+		}
+	}
+	return spec, nil
+}
+
+func parseSpecRow(cols []string, formats map[string]OpCode) (*specRow, error) {
+	if len(cols) < 5 {
+		return nil, errors.New("expected mnemonic, base, extensions, format, fields and operands")
+	}
+	row := &specRow{name: cols[0]}
+	switch cols[1] {
+	case "*":
+	case "rv32":
+		row.xlen = 32
+	case "rv64":
+		row.xlen = 64
+	default:
+		return nil, errors.New("unknown base '" + cols[1] + "'")
+	}
+
+	exts, requires, _ := strings.Cut(cols[2], "+")
+	if exts != "i" {
+		row.extensions = strings.Split(exts, "|")
+		sort.Strings(row.extensions)
+	}
+	row.requires = requires
+	for _, ext := range append(slices.Clone(row.extensions), requires) {
+		if _, ok := extensionVersions[ext]; !ok && ext != "" {
+			return nil, errors.New("unknown extension '" + ext + "'")
+		}
+	}
+
+	format, ok := formats[cols[3]]
+	if !ok {
+		return nil, errors.New("unknown format '" + cols[3] + "'")
+	}
+	values := map[string]int{}
+	for _, col := range cols[4 : len(cols)-1] {
+		name, value, _ := strings.Cut(col, "=")
+		if _, ok := values[name]; ok {
+			return nil, errors.New("field " + name + " is set twice")
+		}
+		if name == "layout" {
+			layout, ok := specLayouts[value]
+			if !ok {
+				return nil, errors.New("unknown layout '" + value + "'")
+			}
+			values[name] = layout
+			continue
+		}
+		v, err := strconv.ParseInt(value, 0, 32)
+		if err != nil {
+			return nil, errors.New("field " + col + " is not a number")
+		}
+		values[name] = int(v)
+	}
+
+	if cols[len(cols)-1] != "-" {
+		for _, form := range strings.Split(cols[len(cols)-1], "|") {
+			row.forms = append(row.forms, strings.Split(form, ","))
+		}
+	} else {
+		row.forms = [][]string{nil}
+	}
+	for _, form := range row.forms {
+		optional := false
+		for _, name := range form {
+			base, isOptional := strings.CutSuffix(name, "?")
+			if _, ok := specOperands[base]; !ok {
+				return nil, errors.New("unknown operand '" + name + "'")
+			}
+			if optional && !isOptional {
+				return nil, errors.New("operand " + name + " follows an optional one")
+			}
+			optional = isOptional
+		}
+	}
+
+	if err := row.encode(format, values); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
+
+// encode builds the opByte of the row from the field values, then its match and mask
+func (r *specRow) encode(format OpCode, values map[string]int) error {
+	fields := specFormats[format]
+	if _, ok := values[fields[0].name]; !ok {
+		return errors.New("missing field " + fields[0].name)
+	}
+	r.pair = OpPair{opType: format}
+	ended := false
+	for _, f := range fields {
+		value, ok := values[f.name]
+		delete(values, f.name)
+		switch {
+		case ok && ended:
+			return errors.New("field " + f.name + " needs the optional fields before it")
+		case !ok && f.optional:
+			ended = true
+			continue
+		case f.size > 0 && (value < 0 || value >= 1<<f.size):
+			return fmt.Errorf("field %s=%d does not fit in %d bits", f.name, value, f.size)
+		}
+		if !ended {
+			r.pair.opByte = append(r.pair.opByte, byte(value))
+		}
+	}
+	for name := range values {
+		return fmt.Errorf("format %s has no field %s", format, name)
+	}
+
+	// slots the encoders read that follow from the operands
+	switch format {
+	case CR:
+		r.pair.opByte = append(r.pair.opByte, byte(len(r.forms[0])))
+	case CI:
+		if len(r.forms[0]) == 0 {
+			r.pair.opByte = append(r.pair.opByte, 0)
+		}
+	case V:
+		if slices.Contains(r.forms[0], "uimm5") {
+			r.pair.opByte = append(r.pair.opByte, 1)
+		}
+	}
+
+	fixed := fixedBits(r.pair)
+	var operandBits uint32
+	for _, form := range r.forms {
+		for _, name := range form {
+			operand := specOperands[strings.TrimSuffix(name, "?")]
+			if operand.fixed != 0 {
+				fixed |= operand.fixed
+				continue
+			}
+			operandBits |= operand.bits
+			if operand.layout {
+				operandBits |= layoutBits(r.pair.opByte[2])
+			}
+		}
+	}
+	r.mask = uint32(1<<(format.size()*8)-1) &^ operandBits
+	r.match = fixed & r.mask
+	return nil
+}
+
+// withOrderings returns the .aq, .rl and .aqrl variants of an atomic row
+func (r *specRow) withOrderings() []*specRow {
+	var rows []*specRow
+	for _, suffix := range []string{".aq", ".rl", ".aqrl"} {
+		ordered := *r
+		ordered.name += suffix
+		ordered.pair.opByte = slices.Clone(r.pair.opByte)
+		ordered.pair.opByte[2] |= atomicOrderings[suffix]
+		ordered.match |= uint32(atomicOrderings[suffix]) << 25
+		rows = append(rows, &ordered)
+	}
+	return rows
+}
+
+// opTypes returns the mnemonic table of the rows of base xlen, 0 for both bases
+func (s *isaSpec) opTypes(xlen int) map[string]OpPair {
+	table := map[string]OpPair{}
+	for _, row := range s.rows {
+		if row.xlen == xlen {
+			table[row.name] = row.pair
+		}
+	}
+	return table
+}
+
+// extensions maps each instruction to the extensions providing it
+func (s *isaSpec) extensions() map[string][]string {
+	exts := map[string][]string{}
+	for _, row := range s.rows {
+		if row.extensions != nil {
+			exts[row.name] = row.extensions
+		}
+	}
+	return exts
+}
+
+// prerequisites maps the instructions needing a second extension to it
+func (s *isaSpec) prerequisites() map[string]string {
+	requires := map[string]string{}
+	for _, row := range s.rows {
+		if row.requires != "" {
+			requires[row.name] = row.requires
+		}
+	}
+	return requires
+}
+
+// byName maps each instruction to its row, the first one for the instructions of both bases
+func (s *isaSpec) byName() map[string]*specRow {
+	rows := map[string]*specRow{}
+	for _, row := range s.rows {
+		if _, ok := rows[row.name]; !ok {
+			rows[row.name] = row
+		}
+	}
+	return rows
+}
+
+// operandForms returns the operand schemas checked before lexing
+func (s *isaSpec) operandForms() map[string][]operandForm {
+	forms := map[string][]operandForm{}
+	for _, row := range s.rows {
+		if _, ok := forms[row.name]; ok {
+			// rev8 and zext.h take the same operands on both bases
+			continue
+		}
+		for _, names := range row.forms {
+			form := operandForm{}
+			for _, name := range names {
+				base, optional := strings.CutSuffix(name, "?")
+				form = append(form, formOperand{base, specOperands[base].kind, optional})
+			}
+			forms[row.name] = append(forms[row.name], form)
+		}
+	}
+	return forms
+}
+
+func bitMask(lo, size int) uint32 {
+	return (1<<size - 1) << lo
+}
+
+// fixedBits places the fixed fields of pair in the instruction, the bytes of opByte past
+// the fields of its format only steer the encoder
+func fixedBits(pair OpPair) uint32 {
+	var fixed uint32
+	for i, f := range specFormats[pair.opType] {
+		if i >= len(pair.opByte) {
+			break
+		}
+		fixed |= uint32(pair.opByte[i]) << f.lo & bitMask(f.lo, f.size)
+	}
+	return fixed
+}
+
+// layoutBits returns the instruction bits holding the immediate of a compressed layout
+func layoutBits(layout byte) uint32 {
+	var bits uint32
+	for i, bit := range compressedLayouts[layout].bits {
+		if bit >= 0 {
+			bits |= 1 << (12 - i)
+		}
+	}
+	return bits
+}
+
+func field(word uint32, lo, size int) uint32 {
+	return word >> lo & (1<<size - 1)
+}
+
+func signedField(word uint32, lo, size int) int64 {
+	return int64(int32(word>>lo<<(32-size)) >> (32 - size))
+}
+
+func intRegisterOperand(lo, size int) specOperand {
+	return specOperand{IntRegister, bitMask(lo, size), false, 0, func(_ *specRow, word uint32, _ int) string {
+		if size == 3 {
+			return intRegisterNames[8+field(word, lo, 3)]
+		}
+		return intRegisterNames[field(word, lo, 5)]
+	}}
+}
+
+func floatRegisterOperand(lo, size int) specOperand {
+	return specOperand{FloatRegister, bitMask(lo, size), false, 0, func(_ *specRow, word uint32, _ int) string {
+		if size == 3 {
+			return floatRegisterNames[8+field(word, lo, 3)]
+		}
+		return floatRegisterNames[field(word, lo, 5)]
+	}}
+}
+
+func vectorRegisterOperand(lo int) specOperand {
+	return specOperand{VectorRegister, bitMask(lo, 5), false, 0, func(_ *specRow, word uint32, _ int) string {
+		return "v" + strconv.Itoa(int(field(word, lo, 5)))
+	}}
+}
+
+// savedRegisterOperand is the 3 bit s0-s7 register of cm.mvsa01 and cm.mva01s
+func savedRegisterOperand(lo int) specOperand {
+	return specOperand{IntRegister, bitMask(lo, 3), false, 0, func(_ *specRow, word uint32, _ int) string {
+		return "s" + strconv.Itoa(int(field(word, lo, 3)))
+	}}
+}
+
+func immediateOperand(bits uint32, value func(word uint32) int64) specOperand {
+	return specOperand{Immediate, bits, false, 0, func(_ *specRow, word uint32, _ int) string {
+		return strconv.FormatInt(value(word), 10)
+	}}
+}
+
+func branchOffset(word uint32) int64 {
+	return signedField(word, 31, 1)<<12 | int64(field(word, 7, 1))<<11 | int64(field(word, 25, 6))<<5 | int64(field(word, 8, 4))<<1
+}
+
+func jumpOffset(word uint32) int64 {
+	return signedField(word, 31, 1)<<20 | int64(field(word, 12, 8))<<12 | int64(field(word, 20, 1))<<11 | int64(field(word, 21, 10))<<1
+}
+
+func memoryText(offset int64, base string) string {
+	return strconv.FormatInt(offset, 10) + "(" + base + ")"
+}
+
+// csrName returns the name of a CSR address, or the address itself when it has none
+func csrName(address int) string {
+	var names []string
+	for name, addr := range CSRNameToAddress {
+		if addr == address {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Sprintf("%#x", address)
+	}
+	sort.Strings(names)
+	return names[0]
+}
+
+// fenceSet spells the 4 bit predecessor or successor set of a fence as iorw letters
+func fenceSet(set uint32) string {
+	var letters string
+	for i, letter := range "iorw" {
+		if set&(0b1000>>i) != 0 {
+			letters += string(letter)
+		}
+	}
+	if letters == "" {
+		return "0"
+	}
+	return letters
+}
+
+// vtypeText spells a vtype immediate as its e32, m1, ta, ma operands
+func vtypeText(vtype uint32) string {
+	var sew, lmul string
+	for name, value := range vectorSEW {
+		if value == int(field(vtype, 3, 3)) {
+			sew = name
+		}
+	}
+	for name, value := range vectorLMUL {
+		if value == int(field(vtype, 0, 3)) {
+			lmul = name
+		}
+	}
+	if sew == "" || lmul == "" || vtype>>8 != 0 {
+		return strconv.Itoa(int(vtype))
+	}
+	tail, mask := "tu", "mu"
+	if field(vtype, 6, 1) == 1 {
+		tail = "ta"
+	}
+	if field(vtype, 7, 1) == 1 {
+		mask = "ma"
+	}
+	return sew + ", " + lmul + ", " + tail + ", " + mask
+}
@@ -0,0 +1,248 @@
+package assembler
+
+import (
+	"encoding/binary"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// specSamples gives a value for each operand name of instructions.spec, the ones
+// depending on the row are filled in by specSampleLine
+var specSamples = map[string]string{
+	"rd": "a0", "rs1": "a1", "rs2": "a2", "fd": "fa0", "fs1": "fa1", "fs2": "fa2", "fs3": "fa3",
+	"vd": "v1", "vs1": "v3", "vs2": "v2", "vs3": "v1", "vm": "v0.t", "rm": "rtz",
+	"imm12": "-5", "shamt": "3", "shamtw": "3", "imm20": "74565", "bimm": "8", "jimm": "16",
+	"zimm": "7", "uimm5": "4", "simm5": "-3", "bs": "1", "csr": "mscratch", "pred": "rw", "succ": "w",
+	"mem": "8(a1)", "smem": "8(a1)", "amem": "(a1)", "pmem": "64(a1)",
+	"vtypei11": "e32, m1, ta, ma", "vtypei10": "e16, m2, tu, mu",
+	"crd": "a0", "crs2": "a1", "cfd": "fa0", "cfs2": "fa1", "rs1p": "a0", "rs2p": "a1", "frs2p": "fa1",
+	"r1s": "s0", "r2s": "s1", "csp": "sp", "sp": "sp", "rlist": "{ra, s0-s1}",
+}
+
+// specSampleLine builds a line using every operand of the first form of row
+func specSampleLine(row *specRow, arch Arch) string {
+	var operands []string
+	for _, name := range row.forms[0] {
+		name = strings.TrimSuffix(name, "?")
+		sample := specSamples[name]
+		switch name {
+		case "cimm":
+			sample = strconv.Itoa(smallestLayoutValue(row))
+			if row.pair.opByte[2] == cLui {
+				sample = "1"
+			}
+		case "cmem":
+			sample = strconv.Itoa(smallestLayoutValue(row)) + "(a2)"
+		case "cspmem":
+			sample = strconv.Itoa(smallestLayoutValue(row)) + "(sp)"
+		case "stackadj":
+			// {ra, s0-s1} rounded up to 16 bytes
+			sample = strconv.Itoa((3*arch.xlen()/8 + 15) &^ 15)
+			if row.name == "cm.push" {
+				sample = "-" + sample
+			}
+		}
+		operands = append(operands, sample)
+	}
+	return strings.TrimSpace(row.name + " " + strings.Join(operands, ", "))
+}
+
+// smallestLayoutValue returns the smallest nonzero immediate the layout of a compressed row holds
+func smallestLayoutValue(row *specRow) int {
+	lowest := 31
+	for _, bit := range compressedLayouts[row.pair.opByte[2]].bits {
+		if bit >= 0 {
+			lowest = min(lowest, bit)
+		}
+	}
+	return 1 << lowest
+}
+
+// TestSpec_Conformance assembles a line for every row of instructions.spec, checks it
+// against the fixed bits of the row, then decodes it and assembles the decoded text again
+func TestSpec_Conformance(t *testing.T) {
+	for _, row := range standardSpec.rows {
+		for _, arch := range []Arch{RV32, RV64} {
+			if row.xlenOr(arch.xlen()) != arch.xlen() {
+				continue
+			}
+			line := specSampleLine(row, arch)
+			t.Run(arch.String()+" "+line, func(t *testing.T) {
+				word, err := assembleSpecLine(line, arch, row.pair.opType.size())
+				if err != nil {
+					t.Fatalf("AssembleLine(%q) error = %v", line, err)
+				}
+				if word&row.mask != row.match {
+					t.Fatalf("AssembleLine(%q) = %#08x, want %#08x under mask %#08x", line, word, row.match, row.mask)
+				}
+				code := binary.LittleEndian.AppendUint32(nil, word)
+				text, size, err := Decode(code[:row.pair.opType.size()], arch)
+				if err != nil || size != row.pair.opType.size() {
+					t.Fatalf("Decode(%#08x) = %q, %d, %v", word, text, size, err)
+				}
+				if name, _, _ := strings.Cut(text, " "); name != row.name {
+					t.Fatalf("Decode(%#08x) = %q, want %s", word, text, row.name)
+				}
+				again, err := assembleSpecLine(text, arch, size)
+				if err != nil || again != word {
+					t.Errorf("AssembleLine(%q) = %#08x, %v, want %#08x", text, again, err, word)
+				}
+			})
+		}
+	}
+}
+
+// TestSpec_Encoders checks InstructionToBinary starts from the match of each row and
+// has an encoder for every operand name
+func TestSpec_Encoders(t *testing.T) {
+	for _, row := range standardSpec.rows {
+		got := fixedBits(row.pair)
+		for _, form := range row.forms {
+			for _, name := range form {
+				// the sp of c.addi16sp is written out, its encoder gives the fixed rd
+				got |= specOperands[strings.TrimSuffix(name, "?")].fixed
+			}
+		}
+		if got&row.mask != row.match {
+			t.Errorf("%s: fixed bits = %#08x, want %#08x under mask %#08x", row.name, got, row.match, row.mask)
+		}
+	}
+	for name := range specOperands {
+		if operandEncoders[name] == nil {
+			t.Errorf("operand %s has no encoder", name)
+		}
+	}
+}
+
+// TestSpec_ReferenceEncodings checks the encodings against the ones LLVM gives, which unlike
+// TestSpec_Conformance do not come from instructions.spec
+func TestSpec_ReferenceEncodings(t *testing.T) {
+	content, err := os.ReadFile("testfile/reference_encodings.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for n, line := range strings.Split(string(content), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		cols := strings.Split(line, "\t")
+		if len(cols) != 3 {
+			t.Fatalf("reference_encodings.txt line %d: expected base, instruction and encoding", n+1)
+		}
+		arch := RV32
+		if cols[0] == "RV64" {
+			arch = RV64
+		}
+		want, err := strconv.ParseUint(cols[2], 0, 32)
+		if err != nil {
+			t.Fatalf("reference_encodings.txt line %d: %v", n+1, err)
+		}
+		t.Run(cols[0]+" "+cols[1], func(t *testing.T) {
+			got, err := assembleSpecLine(cols[1], arch, (len(cols[2])-2)/2)
+			if err != nil || got != uint32(want) {
+				t.Errorf("AssembleLine(%q) = %#08x, %v, want %s", cols[1], got, err, cols[2])
+			}
+		})
+	}
+}
+
+func assembleSpecLine(line string, arch Arch, size int) (uint32, error) {
+	if name, _, _ := strings.Cut(line, " "); instructionPseudos[name] {
+		// sext.b and the like expand to base sequences unless the ISA string enables them
+		words, err := assembleMarchWords(line, strings.ToLower(arch.String())+"i_zba_zbb")
+		if err != nil || len(words) != 1 {
+			return 0, err
+		}
+		return words[0], nil
+	}
+	if size == 2 {
+		halfwords, err := assembleLineHalfwords(line, arch)
+		if err != nil || len(halfwords) != 1 {
+			return 0, err
+		}
+		return uint32(halfwords[0]), nil
+	}
+	words, err := assembleLineWords(line, arch)
+	if err != nil || len(words) != 1 {
+		return 0, err
+	}
+	return words[0], nil
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		code    []byte
+		arch    Arch
+		want    string
+		wantErr bool
+	}{
+		{code: []byte{0x33, 0x05, 0xb5, 0x00}, want: "add a0, a0, a1"},
+		{code: []byte{0x13, 0x05, 0x15, 0x80}, want: "addi a0, a0, -2047"},
+		{code: []byte{0x03, 0x35, 0x85, 0x00}, arch: RV64, want: "ld a0, 8(a0)"},
+		{code: []byte{0x03, 0x35, 0x85, 0x00}, wantErr: true},
+		{code: []byte{0x73, 0x25, 0x00, 0xc0}, want: "csrrs a0, cycle, zero"},
+		{code: []byte{0x0f, 0x00, 0x30, 0x83}, want: "fence.tso"},
+		{code: []byte{0x53, 0x75, 0xb5, 0x00}, want: "fadd.s fa0, fa0, fa1"},
+		{code: []byte{0x53, 0x15, 0xb5, 0x00}, want: "fadd.s fa0, fa0, fa1, rtz"},
+		{code: []byte{0xd7, 0x00, 0x31, 0x00}, want: "vadd.vv v1, v3, v2, v0.t"},
+		{code: []byte{0x57, 0x75, 0x05, 0x0d}, want: "vsetvli a0, a0, e32, m1, ta, ma"},
+		{code: []byte{0x01, 0x00}, want: "c.nop"},
+		{code: []byte{0x05, 0x05}, want: "c.addi a0, 1"},
+		{code: []byte{0x02, 0x85}, want: "c.jr a0"},
+		{code: []byte{0x7d, 0x75}, want: "c.lui a0, 1048575"},
+		{code: []byte{0x19, 0x71}, want: "c.addi16sp sp, -128"},
+		{code: []byte{0x05, 0x20}, want: "c.jal 32"},
+		{code: []byte{0x05, 0x25}, arch: RV64, want: "c.addiw a0, 1"},
+		{code: []byte{0x62, 0xb8}, want: "cm.push {ra, s0-s1}, -16"},
+		{code: []byte{0x62, 0xb8}, arch: RV64, want: "cm.push {ra, s0-s1}, -32"},
+		{code: []byte{0x00, 0x00}, wantErr: true},
+		{code: []byte{0x33}, wantErr: true},
+		{code: []byte{0x33, 0x05}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.arch.String()+" "+tt.want, func(t *testing.T) {
+			got, size, err := Decode(tt.code, tt.arch)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Decode(% x) error = %v, wantErr %v", tt.code, err, tt.wantErr)
+			}
+			if err == nil && (got != tt.want || size != len(tt.code)) {
+				t.Errorf("Decode(% x) = %q, %d, want %q", tt.code, got, size, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{"valid", "add * i R opcode=0x33 funct3=0 funct7=0 rd,rs1,rs2 # comment\n\n", false},
+		{"alternatives", "jalr * i I opcode=0x67 funct3=0 rd,rs1,imm12|rd,mem", false},
+		{"per base", "rev8 rv32 zbb|zbkb R opcode=0x13 funct3=5 funct7=0x34 rs2=0x18 rd,rs1\nrev8 rv64 zbb|zbkb R opcode=0x13 funct3=5 funct7=0x35 rs2=0x18 rd,rs1", false},
+		{"missing column", "add * i R rd,rs1,rs2", true},
+		{"unknown base", "add rv128 i R opcode=0x33 rd,rs1,rs2", true},
+		{"unknown extension", "add * q R opcode=0x33 rd,rs1,rs2", true},
+		{"unknown format", "add * i Q opcode=0x33 rd,rs1,rs2", true},
+		{"unknown field", "add * i R opcode=0x33 funct9=1 rd,rs1,rs2", true},
+		{"field too wide", "add * i R opcode=0x33 funct3=8 rd,rs1,rs2", true},
+		{"field set twice", "add * i R opcode=0x33 opcode=0x33 rd,rs1,rs2", true},
+		{"no opcode", "add * i R funct3=0 rd,rs1,rs2", true},
+		{"unknown layout", "c.x * c CI op=1 funct3=0 layout=imm7 crd,cimm", true},
+		{"optional gap", "fence * i FENCE opcode=0x0F succ=1 -", true},
+		{"unknown operand", "add * i R opcode=0x33 rd,rs1,rs9", true},
+		{"required after optional", "add * i R opcode=0x33 rd,rs1?,rs2", true},
+		{"defined twice", "add * i R opcode=0x33 rd,rs1,rs2\nadd rv64 i R opcode=0x3B rd,rs1,rs2", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
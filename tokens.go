@@ -25,6 +25,9 @@ const (
 	varValue
 	varLabel
 	varSize
+	roundingMode
+	vectorMask
+	registerList
 )
 
 type Token struct {
@@ -33,6 +36,15 @@ type Token struct {
 	opPair    *OpPair
 	children  []*Token
 	parent    *Token
+	// 16 bit equivalents of an instruction written under .option rvc,
+	// emptied by the compilation when none of them fits anymore
+	compressed []*Token
+	// instructions replacing a branch whose target is out of range, set by the
+	// compilation and laid out in its place from then on
+	relaxed []*Token
+	// written with .insn, its fields are encoded as given without the checks of the
+	// instruction sharing its opcode
+	insn bool
 }
 
 func NewToken(tokenType TokenType, value string, parent *Token, pair_optional ...*OpPair) *Token {
@@ -77,7 +89,11 @@ func (t *Token) getValueFromLiteral() (int, error) {
 func (t *Token) getRegisterFromABI() (int, error) {
 	res, err := matchTokenValid(t.value)
 	if err != nil {
-		return -1, err
+		res, ferr := matchFloatRegisterValid(t.value)
+		if ferr != nil {
+			return -1, err
+		}
+		return res, nil
 	}
 	return res, nil
 }
@@ -154,3 +170,99 @@ func matchTokenValid(val string) (int, error) {
 		return -1, fmt.Errorf("invalid register: %d", register)
 	}
 }
+
+// matchFloatRegisterValid resolves the f0-f31 register file and its ABI names
+func matchFloatRegisterValid(val string) (int, error) {
+	switch val {
+	case "f0", "ft0":
+		return 0, nil
+	case "f1", "ft1":
+		return 1, nil
+	case "f2", "ft2":
+		return 2, nil
+	case "f3", "ft3":
+		return 3, nil
+	case "f4", "ft4":
+		return 4, nil
+	case "f5", "ft5":
+		return 5, nil
+	case "f6", "ft6":
+		return 6, nil
+	case "f7", "ft7":
+		return 7, nil
+	case "f8", "fs0":
+		return 8, nil
+	case "f9", "fs1":
+		return 9, nil
+	case "f10", "fa0":
+		return 10, nil
+	case "f11", "fa1":
+		return 11, nil
+	case "f12", "fa2":
+		return 12, nil
+	case "f13", "fa3":
+		return 13, nil
+	case "f14", "fa4":
+		return 14, nil
+	case "f15", "fa5":
+		return 15, nil
+	case "f16", "fa6":
+		return 16, nil
+	case "f17", "fa7":
+		return 17, nil
+	case "f18", "fs2":
+		return 18, nil
+	case "f19", "fs3":
+		return 19, nil
+	case "f20", "fs4":
+		return 20, nil
+	case "f21", "fs5":
+		return 21, nil
+	case "f22", "fs6":
+		return 22, nil
+	case "f23", "fs7":
+		return 23, nil
+	case "f24", "fs8":
+		return 24, nil
+	case "f25", "fs9":
+		return 25, nil
+	case "f26", "fs10":
+		return 26, nil
+	case "f27", "fs11":
+		return 27, nil
+	case "f28", "ft8":
+		return 28, nil
+	case "f29", "ft9":
+		return 29, nil
+	case "f30", "ft10":
+		return 30, nil
+	case "f31", "ft11":
+		return 31, nil
+	default:
+		return -1, fmt.Errorf("invalid float register: %s", val)
+	}
+}
+
+// matchVectorRegisterValid resolves the v0-v31 vector register file
+func matchVectorRegisterValid(val string) (int, error) {
+	if len(val) < 2 || val[0] != 'v' {
+		return -1, fmt.Errorf("invalid vector register: %s", val)
+	}
+	reg, err := strconv.Atoi(val[1:])
+	if err != nil || reg < 0 || reg > 31 || strconv.Itoa(reg) != val[1:] {
+		return -1, fmt.Errorf("invalid vector register: %s", val)
+	}
+	return reg, nil
+}
+
+// savedRegisterIndex returns n for the saved register sn (s0 is x8, s1 x9, s2-s11 x18-x27),
+// or -1 when reg is not a saved register
+func savedRegisterIndex(reg int) int {
+	switch {
+	case reg == 8 || reg == 9:
+		return reg - 8
+	case reg >= 18 && reg <= 27:
+		return reg - 16
+	}
+	return -1
+}
@@ -127,6 +127,7 @@ func TestToken_getRegisterFromABI(t *testing.T) {
 	}{
 		{"Valid name a1", "a1", 11, false},
 		{"Valid name x2", "x2", 2, false},
+		{"Valid float name fa1", "fa1", 11, false},
 		{"Invalid name", "xyz", -1, true},
 	}
 	for _, tt := range tests {
@@ -167,3 +168,59 @@ func Test_matchTokenValid(t *testing.T) {
 		})
 	}
 }
+
+func Test_matchFloatRegisterValid(t *testing.T) {
+	tests := []struct {
+		val     string
+		want    int
+		wantErr bool
+	}{
+		{"f0", 0, false},
+		{"ft0", 0, false},
+		{"fs1", 9, false},
+		{"fa0", 10, false},
+		{"fs11", 27, false},
+		{"ft11", 31, false},
+		{"x1", -1, true},
+		{"f32", -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			got, err := matchFloatRegisterValid(tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("matchFloatRegisterValid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("matchFloatRegisterValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_matchVectorRegisterValid(t *testing.T) {
+	tests := []struct {
+		val     string
+		want    int
+		wantErr bool
+	}{
+		{"v0", 0, false},
+		{"v8", 8, false},
+		{"v31", 31, false},
+		{"v32", -1, true},
+		{"v01", -1, true},
+		{"v-1", -1, true},
+		{"v", -1, true},
+		{"a0", -1, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.val, func(t *testing.T) {
+			got, err := matchVectorRegisterValid(tt.val)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("matchVectorRegisterValid() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("matchVectorRegisterValid() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"math/bits"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -82,6 +84,10 @@ func TranslateSType(opcode int, func3 int, rs1 int, rs2 int, imm int) uint32 {
 	return res
 }
 
+// errOutOfRange is returned for a B or J type target the offset cannot reach, the
+// compilation replaces such an instruction by a longer sequence
+var errOutOfRange = errors.New("target out of range")
+
 func TranslateBType(opcode int, func3 int, rs1 int, rs2 int, imm int) uint32 {
 	//masks
 	opcode &= 0b1111111
@@ -158,147 +164,652 @@ func TranslateJType(opcode int, rd int, imm int) uint32 {
 	return res
 }
 
-func (p *Program) InstructionToBinary(t *Token, relativeInstrCount int) (uint32, error) {
-	if t.tokenType != instruction {
-		return 0, errors.New("expected instruction")
+func TranslateR4Type(opcode int, rd int, func3 int, rs1 int, rs2 int, format int, rs3 int) uint32 {
+	//masks
+	opcode &= 0b1111111
+	rd &= 0b11111
+	func3 &= 0b111
+	rs1 &= 0b11111
+	rs2 &= 0b11111
+	format &= 0b11
+	rs3 &= 0b11111
+
+	//shifts
+	res := uint32(rs3)
+	res <<= 2
+	res |= uint32(format)
+	res <<= 5
+	res |= uint32(rs2)
+	res <<= 5
+	res |= uint32(rs1)
+	res <<= 3
+	res |= uint32(func3)
+	res <<= 5
+	res |= uint32(rd)
+	res <<= 7
+	res |= uint32(opcode)
+
+	return res
+}
+
+// scatterCompressedImmediate places the bits of imm at the positions given by the layout
+func scatterCompressedImmediate(imm int, layout int) uint32 {
+	var res uint32
+	for i, bit := range compressedLayouts[layout].bits {
+		if bit >= 0 {
+			res |= uint32(imm>>bit&1) << (12 - i)
+		}
 	}
-	if t.value == "ecall" || t.value == "ebreak" {
-		opcode := int(t.opPair.opByte[0])
-		func3 := int(t.opPair.opByte[1])
-		rd := 0
-		rs1 := 0
-		func12 := int(t.opPair.opByte[2])
+	return res
+}
 
-		return TranslateIType(opcode, rd, func3, rs1, func12), nil
+// stackAdjustment returns the spimm field of a push or pop moving sp by adj bytes: the space
+// the registers of rlist take rounded up to 16 bytes, plus 0 to 48 extra bytes,
+// negative for cm.push
+func (p *Program) stackAdjustment(t *Token, rlist int, adj int) (int, error) {
+	registers := rlist - 3
+	if rlist == 15 {
+		// {ra, s0-s11}, s10 is never saved without s11
+		registers = 13
 	}
-	switch t.opPair.opType {
-	case R: // add x0, x0, x0
-		if len(t.children) != 3 {
-			return 0, errors.New(t.value + " is not a valid instruction")
-		}
-		opcode := int(t.opPair.opByte[0])
-		func3 := int(t.opPair.opByte[1])
-		func7 := int(t.opPair.opByte[2])
-		rd, err := t.children[0].getRegisterNumericValue()
-		if err != nil {
-			return 0, err
+	base := (registers*p.arch.xlen()/8 + 15) &^ 15
+	if t.opPair.opByte[2] == 0b11000 {
+		adj = -adj
+	}
+	spimm := (adj - base) / 16
+	if adj < base || adj > base+48 || adj%16 != 0 {
+		sign := ""
+		if t.opPair.opByte[2] == 0b11000 {
+			sign = "-"
 		}
-		rs1, err := t.children[1].getRegisterNumericValue()
-		if err != nil {
-			return 0, err
+		return 0, fmt.Errorf("%s: stack adjustment must be %s%d to %s%d in steps of 16", t.value, sign, base, sign, base+48)
+	}
+	return spimm, nil
+}
+
+// vectorRegister returns the number of a v0-v31 register operand
+func vectorRegister(t *Token, tok *Token) (int, error) {
+	if tok.tokenType != register {
+		return 0, fmt.Errorf("%s: expected a vector register, got %s", t.value, tok.value)
+	}
+	reg, err := matchVectorRegisterValid(tok.value)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w", t.value, err)
+	}
+	return reg, nil
+}
+
+// integerRegister returns the number of an x0-x31 register operand
+func integerRegister(t *Token, tok *Token) (int, error) {
+	reg := -1
+	if tok.tokenType == register {
+		reg, _ = matchTokenValid(tok.value)
+	}
+	if reg < 0 {
+		return 0, fmt.Errorf("%s: expected an integer register, got %s", t.value, tok.value)
+	}
+	return reg, nil
+}
+
+// vectorImmediate returns the 5 bit immediate of the .vi forms, signed unless used as a shift amount
+func vectorImmediate(t *Token, tok *Token, unsigned bool) (int, error) {
+	if tok.tokenType != literal {
+		return 0, fmt.Errorf("%s: expected an immediate, got %s", t.value, tok.value)
+	}
+	imm, err := parseIntValue(tok.value)
+	if err != nil {
+		return 0, err
+	}
+	minVal, maxVal := -16, 15
+	if unsigned {
+		minVal, maxVal = 0, 31
+	}
+	if imm < minVal || imm > maxVal {
+		return 0, fmt.Errorf("%s: immediate %d is out of range %d-%d", t.value, imm, minVal, maxVal)
+	}
+	return imm & 0b11111, nil
+}
+
+// compressedRegister returns the 3 bit field of the registers x8-x15 (f8-f15)
+// that CIW, CL, CS, CA and CB instructions can address
+func compressedRegister(t *Token, tok *Token) (int, error) {
+	reg, err := tok.getRegisterNumericValue()
+	if err != nil {
+		return 0, err
+	}
+	if reg < 8 || reg > 15 {
+		return 0, fmt.Errorf("%s: register %s is not one of x8-x15", t.value, tok.value)
+	}
+	return reg - 8, nil
+}
+
+// compressedImmediate returns the immediate, label offset or offset(sp) operand of a compressed instruction
+func (p *Program) compressedImmediate(t *Token, tok *Token, relativeInstrCount int, spBased bool) (int, error) {
+	if spBased != (tok.tokenType == complexValue) {
+		return 0, errors.New(t.value + " is not a valid instruction")
+	}
+	base, imm, err := p.parseComplexValue(tok, relativeInstrCount)
+	if err != nil {
+		return 0, err
+	}
+	if spBased && base != 2 {
+		return 0, fmt.Errorf("%s: base register must be sp", t.value)
+	}
+	return imm, nil
+}
+
+// checkCompressedImmediate verifies imm fits the layout: in range, aligned to the
+// lowest encoded bit and nonzero when the zero encoding is reserved
+func (p *Program) checkCompressedImmediate(t *Token, imm int, layout int) error {
+	l := compressedLayouts[layout]
+	low, top := 31, 0
+	for _, bit := range l.bits {
+		if bit >= 0 {
+			low = min(low, bit)
+			top = max(top, bit)
 		}
-		rs2, err := t.children[2].getRegisterNumericValue()
-		if err != nil {
-			return 0, err
+	}
+	minVal, maxVal := 0, 1<<(top+1)-1
+	if l.signed {
+		minVal, maxVal = -(1 << top), 1<<top-1
+	}
+	if layout == cShamt {
+		maxVal = p.arch.xlen() - 1
+	}
+	if imm < minVal || imm > maxVal {
+		return fmt.Errorf("%s: immediate %d is out of range %d-%d", t.value, imm, minVal, maxVal)
+	}
+	if imm&(1<<low-1) != 0 {
+		return fmt.Errorf("%s: immediate %d is not a multiple of %d", t.value, imm, 1<<low)
+	}
+	if l.nonzero && imm == 0 {
+		return fmt.Errorf("%s: immediate must be nonzero", t.value)
+	}
+	return nil
+}
+
+// operandEncoder returns the operand written as tok placed in the instruction, the
+// bits outside the ones specOperands gives for it are dropped
+type operandEncoder func(e *encoding, operand specOperand, tok *Token) (uint32, error)
+
+// encoding is an instruction being encoded, word holds its fixed fields and the operands
+// encoded so far, which the later operands check against
+type encoding struct {
+	p    *Program
+	t    *Token
+	rel  int
+	word uint32
+	form []string
+}
+
+// defaultForms gives the operands of the instructions written with .insn or registered
+// on an InstructionSet, instructions.spec gives the ones of the standard instructions
+var defaultForms = map[OpCode][][]string{
+	R:  {{"rd", "rs1", "rs2", "rm?"}},
+	R4: {{"fd", "fs1", "fs2", "fs3", "rm?"}},
+	I:  {{"rd", "rs1", "imm12"}, {"rd", "mem"}},
+	S:  {{"rs2", "smem"}},
+	B:  {{"rs1", "rs2", "bimm"}},
+	U:  {{"rd", "imm20"}},
+	J:  {{"rd", "jimm"}},
+	A:  {{"rd", "rs2", "amem"}, {"rd", "amem"}},
+}
+
+// operandEncoders lists the encoder of each operand name of specOperands
+var operandEncoders = map[string]operandEncoder{
+	"rd":       encodeRegister,
+	"rs1":      encodeRegister,
+	"rs2":      encodeRegister,
+	"fd":       encodeRegister,
+	"fs1":      encodeRegister,
+	"fs2":      encodeRegister,
+	"fs3":      encodeRegister,
+	"vd":       encodeVectorRegister,
+	"vs1":      encodeVectorRegister,
+	"vs2":      encodeVectorRegister,
+	"vs3":      encodeVectorRegister,
+	"vm":       encodeVectorMask,
+	"rm":       encodeRoundingMode,
+	"imm12":    encodeImmediate,
+	"shamt":    encodeShiftAmount(func(p *Program) int { return p.arch.xlen() }),
+	"shamtw":   encodeShiftAmount(func(*Program) int { return 32 }),
+	"imm20":    encodeUpperImmediate,
+	"bimm":     encodeBranchOffset,
+	"jimm":     encodeJumpOffset,
+	"zimm":     encodeNumber,
+	"uimm5":    encodeVectorImmediate(true),
+	"simm5":    encodeVectorImmediate(false),
+	"bs":       encodeByteSelect,
+	"csr":      encodeNumber,
+	"pred":     encodeNumber,
+	"succ":     encodeNumber,
+	"mem":      encodeMemory,
+	"smem":     encodeStoreMemory,
+	"amem":     encodeAddress,
+	"pmem":     encodePrefetchMemory,
+	"vtypei11": encodeNumber,
+	"vtypei10": encodeNumber,
+	"crd":      encodeRegister,
+	"crs2":     encodeRegister,
+	"cfd":      encodeRegister,
+	"cfs2":     encodeRegister,
+	"rs1p":     encodeCompressedRegister,
+	"rs2p":     encodeCompressedRegister,
+	"frs2p":    encodeCompressedRegister,
+	"r1s":      encodeSavedRegister,
+	"r2s":      encodeSecondSavedRegister,
+	"csp":      encodeStackPointer,
+	"sp":       encodeStackPointer,
+	"cimm":     encodeCompressedImmediate,
+	"cmem":     encodeCompressedMemory,
+	"cspmem":   encodeStackMemory,
+	"rlist":    encodeRegisterList,
+	"stackadj": encodeStackAdjustment,
+}
+
+// place shifts value to the lowest bit of the operand
+func (o specOperand) place(value int) uint32 {
+	return uint32(value) << bits.TrailingZeros32(o.bits)
+}
+
+// encodingForms returns the operand forms t may be written with
+func encodingForms(t *Token) [][]string {
+	if row, ok := instructionRows[t.value]; ok && !t.insn && row.pair.opType == t.opPair.opType {
+		return row.forms
+	}
+	if t.opPair.opType == R && len(t.opPair.opByte) > 3 {
+		// a registered instruction with a fixed rs2
+		return [][]string{{"rd", "rs1", "rm?"}}
+	}
+	return defaultForms[t.opPair.opType]
+}
+
+// assignOperands pairs the operands of form with the tokens written, an optional operand
+// left out gets nil; ok is false when the tokens do not fit the form
+func assignOperands(form []string, children []*Token) (operands []*Token, ok bool) {
+	operands = make([]*Token, len(form))
+	next := 0
+	for i, name := range form {
+		base, optional := strings.CutSuffix(name, "?")
+		if next < len(children) && operandTakes(base, children[next]) {
+			operands[i] = children[next]
+			next++
+		} else if !optional {
+			return nil, false
 		}
-		return TranslateRType(opcode, rd, func3, rs1, rs2, func7), nil
-	case I: // lw x0, 0(x0)
-		opcode := int(t.opPair.opByte[0])
-		func3 := int(t.opPair.opByte[1])
-		rd, err := t.children[0].getRegisterNumericValue()
-		if err != nil {
-			return 0, err
+	}
+	return operands, next == len(children)
+}
+
+// operandTakes reports whether tok can be written for the operand name
+func operandTakes(name string, tok *Token) bool {
+	switch name {
+	case "vm":
+		return tok.tokenType == vectorMask
+	case "rm":
+		return tok.tokenType == roundingMode
+	}
+	return tok.tokenType != vectorMask && tok.tokenType != roundingMode
+}
+
+// InstructionToBinary encodes t: the fixed fields of its opPair, then each operand of
+// the first form its tokens fit, placed in the bits specOperands gives for it
+func (p *Program) InstructionToBinary(t *Token, relativeInstrCount int) (uint32, error) {
+	if t.tokenType != instruction {
+		return 0, errors.New("expected instruction")
+	}
+	if t.opPair.opType == INSN || t.opPair.opType == CINSN {
+		// .insn 0x0000000b
+		var res uint32
+		for i, b := range t.opPair.opByte {
+			res |= uint32(b) << (8 * i)
 		}
-		rs1, imm, err := p.parseComplexValue(t.children[1], relativeInstrCount)
-		if err != nil {
-			return 0, err
+		return res, nil
+	}
+	forms := encodingForms(t)
+	if forms == nil {
+		return 0, fmt.Errorf("%s: format %s has no encoder", t.value, t.opPair.opType)
+	}
+	for _, form := range forms {
+		operands, ok := assignOperands(form, t.children)
+		if !ok {
+			continue
 		}
-		if len(t.children) > 2 {
-			//if two children and rs1 = 0 then we can guess that previous one was a register (addi X5, X5, 5)
-			if rs1 == 0 {
-				rs1 = imm
-			}
-
-			_, imm, err = p.parseComplexValue(t.children[2], relativeInstrCount)
-			if err != nil {
+		e := &encoding{p, t, relativeInstrCount, fixedBits(*t.opPair), form}
+		for i, name := range form {
+			if err := e.encode(name, operands[i]); err != nil {
 				return 0, err
 			}
 		}
-		imm |= (int(t.opPair.opByte[2]) << 5)
-		return TranslateIType(opcode, rd, func3, rs1, imm), nil
-	case S: //sw x0, 0(x0)
-		opcode := int(t.opPair.opByte[0])
-		func3 := int(t.opPair.opByte[1])
-		rs1, err := t.children[0].getRegisterNumericValue()
-		if err != nil {
-			return 0, err
-		}
-		rs2, imm, err := p.parseComplexValue(t.children[1], relativeInstrCount)
-		if err != nil {
-			return 0, err
-		}
-		return TranslateSType(opcode, func3, rs1, rs2, imm), nil
-	case B: // beq x0, x0, 0
-		opcode := int(t.opPair.opByte[0])
-		func3 := int(t.opPair.opByte[1])
-		rs1, err := t.children[0].getRegisterNumericValue()
-		if err != nil {
-			return 0, err
-		}
-		rs2, err := t.children[1].getRegisterNumericValue()
-		tmp, imm, err := p.parseComplexValue(t.children[2], relativeInstrCount)
-		if err != nil {
-			return 0, err
-		}
-		if imm == 0 {
-			imm = tmp
+		return e.word, nil
+	}
+	for _, child := range t.children {
+		if child.tokenType == roundingMode {
+			return 0, errors.New(t.value + " does not take a rounding mode")
 		}
-		return TranslateBType(opcode, func3, rs1, rs2, imm), nil
-	case U: // lui x0, 0
-		opcode := int(t.opPair.opByte[0])
-		rd, err := t.children[0].getRegisterNumericValue()
-		if err != nil {
-			return 0, err
+	}
+	return 0, errors.New(t.value + " is not a valid instruction")
+}
+
+// encode adds the operand name written as tok to the word, an optional operand
+// replaces the default the fixed fields give it
+func (e *encoding) encode(name string, tok *Token) error {
+	base, optional := strings.CutSuffix(name, "?")
+	operand := specOperands[base]
+	mask := operand.bits
+	if operand.layout {
+		mask |= layoutBits(e.t.opPair.opByte[2])
+	}
+	if tok == nil {
+		if base == "vm" {
+			// left out, the instruction is unmasked
+			e.word |= mask
 		}
+		return nil
+	}
+	val, err := operandEncoders[base](e, operand, tok)
+	if err != nil {
+		return err
+	}
+	if optional {
+		e.word &^= mask
+	}
+	e.word |= val & mask
+	return nil
+}
 
-		tmp, imm, err := p.parseComplexValue(t.children[1], relativeInstrCount)
+func encodeRegister(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	reg, err := tok.getRegisterNumericValue()
+	if err != nil {
+		return 0, err
+	}
+	// a zero register would encode another instruction (c.jr x0 is reserved, c.mv rd, x0 is c.jr)
+	if reg == 0 && e.t.opPair.opType == CR {
+		return 0, fmt.Errorf("%s: register %s is not allowed", e.t.value, tok.value)
+	}
+	return operand.place(reg), nil
+}
+
+func encodeVectorRegister(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	reg, err := vectorRegister(e.t, tok)
+	return operand.place(reg), err
+}
+
+// encodeVectorMask encodes v0.t, vm is 0 when the instruction is masked
+func encodeVectorMask(e *encoding, _ specOperand, _ *Token) (uint32, error) {
+	if slices.Contains(e.form, "vd") && field(e.word, 7, 5) == 0 {
+		return 0, errors.New(e.t.value + ": the destination of a masked instruction cannot be v0")
+	}
+	return 0, nil
+}
+
+// encodeRoundingMode replaces the funct3 of the instructions defaulting to dyn
+func encodeRoundingMode(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	if field(e.word, 12, 3) != uint32(roundingModes["dyn"]) {
+		return 0, errors.New(e.t.value + " does not take a rounding mode")
+	}
+	return operand.place(roundingModes[tok.value]), nil
+}
+
+func encodeImmediate(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	_, imm, err := e.p.parseComplexValue(tok, e.rel)
+	return operand.place(imm), err
+}
+
+// encodeShiftAmount returns the encoder of a shift amount below the width bits shifted,
+// the *w shifts of RV64 always operate on 32 bits
+func encodeShiftAmount(width func(p *Program) int) operandEncoder {
+	return func(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+		_, imm, err := e.p.parseComplexValue(tok, e.rel)
 		if err != nil {
 			return 0, err
 		}
-		if imm == 0 {
-			imm = tmp
+		if shamtBits := width(e.p); imm < 0 || imm >= shamtBits {
+			return 0, fmt.Errorf("%s: shift amount %d is out of range 0-%d", e.t.value, imm, shamtBits-1)
 		}
-		return TranslateUType(opcode, rd, imm), nil
-	case J: // jal x0, 0
-		opcode := int(t.opPair.opByte[0])
-		rd, err := t.children[0].getRegisterNumericValue()
-		if err != nil {
-			return 0, err
+		return operand.place(imm), nil
+	}
+}
+
+// targetValue returns the immediate or label offset of lui, auipc, a branch or a jump,
+// offset(label) gives the offset of the label when offset is 0
+func (p *Program) targetValue(tok *Token, relativeInstrCount int) (int, error) {
+	tmp, imm, err := p.parseComplexValue(tok, relativeInstrCount)
+	if imm == 0 {
+		imm = tmp
+	}
+	return imm, err
+}
+
+func encodeUpperImmediate(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	imm, err := e.p.targetValue(tok, e.rel)
+	return operand.place(imm), err
+}
+
+func encodeBranchOffset(e *encoding, _ specOperand, tok *Token) (uint32, error) {
+	imm, err := e.p.targetValue(tok, e.rel)
+	if err != nil {
+		return 0, err
+	}
+	if imm < -1<<12 || imm >= 1<<12 {
+		return 0, fmt.Errorf("%s: %w: %d", e.t.value, errOutOfRange, imm)
+	}
+	return TranslateBType(0, 0, 0, 0, imm), nil
+}
+
+func encodeJumpOffset(e *encoding, _ specOperand, tok *Token) (uint32, error) {
+	imm, err := e.p.targetValue(tok, e.rel)
+	if err != nil {
+		return 0, err
+	}
+	if imm < -1<<20 || imm >= 1<<20 {
+		if field(e.word, 7, 5) == 0 {
+			// relaxing a plain jump would clobber a register, tail names t1 explicitly
+			return 0, fmt.Errorf("%s: %w: %d, use tail to jump further than ±1 MiB", e.t.value, errOutOfRange, imm)
 		}
-		tmp, imm, err := p.parseComplexValue(t.children[1], relativeInstrCount)
-		if err != nil {
-			return 0, err
+		return 0, fmt.Errorf("%s: %w: %d", e.t.value, errOutOfRange, imm)
+	}
+	return TranslateJType(0, 0, imm), nil
+}
+
+// encodeNumber encodes the operands the lexer already turned into a number: csr
+// addresses, fence sets, vtype and the uimm of the csr*i instructions
+func encodeNumber(_ *encoding, operand specOperand, tok *Token) (uint32, error) {
+	val, err := parseIntValue(tok.value)
+	return operand.place(val), err
+}
+
+func encodeVectorImmediate(unsigned bool) operandEncoder {
+	return func(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+		imm, err := vectorImmediate(e.t, tok, unsigned)
+		return operand.place(imm), err
+	}
+}
+
+func encodeByteSelect(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	if tok.tokenType != literal {
+		return 0, errors.New(e.t.value + " is not a valid instruction")
+	}
+	bs, err := parseIntValue(tok.value)
+	if err != nil {
+		return 0, err
+	}
+	if bs < 0 || bs > 3 {
+		return 0, fmt.Errorf("%s: byte select %d is out of range 0-3", e.t.value, bs)
+	}
+	return operand.place(bs), nil
+}
+
+// encodeMemory encodes offset(rs1) in the rs1 and imm[11:0] fields
+func encodeMemory(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	rs1, imm, err := e.p.parseComplexValue(tok, e.rel)
+	return operand.place(rs1) | uint32(imm)<<20, err
+}
+
+func encodeStoreMemory(e *encoding, _ specOperand, tok *Token) (uint32, error) {
+	rs1, imm, err := e.p.parseComplexValue(tok, e.rel)
+	return TranslateSType(0, 0, rs1, 0, imm), err
+}
+
+// encodeAddress encodes the (rs1) of atomics, cache-block and vector memory instructions,
+// the vector lexer already strips the parentheses
+func encodeAddress(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	if tok.tokenType == register {
+		reg, err := integerRegister(e.t, tok)
+		return operand.place(reg), err
+	}
+	rs1, offset, err := e.p.parseComplexValue(tok, e.rel)
+	if err != nil {
+		return 0, err
+	}
+	if offset != 0 {
+		return 0, errors.New(e.t.value + " does not take an address offset")
+	}
+	return operand.place(rs1), nil
+}
+
+func encodePrefetchMemory(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	if tok.tokenType != complexValue {
+		return 0, errors.New(e.t.value + " expects an offset(rs1) operand")
+	}
+	rs1, offset, err := e.p.parseComplexValue(tok, e.rel)
+	if err != nil {
+		return 0, err
+	}
+	// the prefetch offset only holds imm[11:5], the low bits select the hint
+	if offset < -2048 || offset > 2047 || offset%32 != 0 {
+		return 0, fmt.Errorf("%s: offset %d must be a multiple of 32 in range -2048-2016", e.t.value, offset)
+	}
+	return operand.place(rs1) | uint32(offset)<<20, nil
+}
+
+func encodeCompressedRegister(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	reg, err := compressedRegister(e.t, tok)
+	return operand.place(reg), err
+}
+
+// encodeSavedRegister encodes the s0-s7 operands of cm.mvsa01 and cm.mva01s
+func encodeSavedRegister(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	reg, err := tok.getRegisterNumericValue()
+	if err != nil {
+		return 0, err
+	}
+	index := savedRegisterIndex(reg)
+	if index < 0 || index > 7 {
+		return 0, fmt.Errorf("%s: register %s is not one of s0-s7", e.t.value, tok.value)
+	}
+	return operand.place(index), nil
+}
+
+func encodeSecondSavedRegister(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	val, err := encodeSavedRegister(e, operand, tok)
+	if err != nil {
+		return 0, err
+	}
+	// both saved registers receive a0 and a1, the same one twice is reserved
+	if e.t.opPair.opByte[2] == 0b01 && field(val, 2, 3) == field(e.word, 7, 3) {
+		return 0, fmt.Errorf("%s: registers must be different", e.t.value)
+	}
+	return val, nil
+}
+
+// encodeStackPointer checks the sp operands, the fixed field of the ones held in rd
+func encodeStackPointer(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	if reg, err := tok.getRegisterNumericValue(); err != nil || reg != 2 {
+		return 0, fmt.Errorf("%s: register %s must be sp", e.t.value, tok.value)
+	}
+	return operand.fixed, nil
+}
+
+func encodeCompressedImmediate(e *encoding, _ specOperand, tok *Token) (uint32, error) {
+	layout := int(e.t.opPair.opByte[2])
+	imm, err := e.p.compressedImmediate(e.t, tok, e.rel, false)
+	if err != nil {
+		return 0, err
+	}
+	if layout == cLui {
+		if rd := field(e.word, 7, 5); rd == 0 || rd == 2 {
+			return 0, fmt.Errorf("%s: destination cannot be %s", e.t.value, e.t.children[0].value)
 		}
-		if imm == 0 {
-			imm = tmp
+		// the operand is the upper immediate like lui, values 0xfffe0-0xfffff stand for -32 to -1
+		if imm >= 0xFFFE0 && imm <= 0xFFFFF {
+			imm -= 0x100000
 		}
-		return TranslateJType(opcode, rd, imm), nil
-
-		//COMPRESSED INSTRUCTIONS HAVE BEEN IMPLEMENTED VIA PREPROCESSER AS THE CPU IS NOT
-		//SUPPOSED TO BE CAPABLE OF HANDLING COMPRESSED INSTRUCTIONS
-	case CI:
-		return 0, errors.New("todo: compressed instructions (CI)") //todo
-	case CSS:
-		return 0, errors.New("todo: compressed instructions (CSS)") //todo
-	case CL:
-		return 0, errors.New("todo: compressed instructions (CL)") //todo
-	case CJ:
-		return 0, errors.New("todo: compressed instructions (CJ)") //todo
-	case CR:
-		return 0, errors.New("todo: compressed instructions (CR)") //todo
-	case CB:
-		return 0, errors.New("todo: compressed instructions (CB)") //todo
-	case CIW:
-		return 0, errors.New("todo: compressed instructions (CIW)") //todo
-	case CS:
-		return 0, errors.New("todo: compressed instructions (CS)") //todo
-	default:
-		return 0, errors.New("unhandled default case")
+		imm <<= 12
+	}
+	if err := e.p.checkCompressedImmediate(e.t, imm, layout); err != nil {
+		return 0, err
+	}
+	return scatterCompressedImmediate(imm, layout), nil
+}
+
+// encodeCompressedMemory encodes offset(rs1') of c.lw and c.sw like instructions
+func encodeCompressedMemory(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	if tok.tokenType != complexValue {
+		return 0, errors.New(e.t.value + " is not a valid instruction")
+	}
+	base, err := compressedRegister(e.t, tok.children[1])
+	if err != nil {
+		return 0, err
+	}
+	_, imm, err := e.p.parseComplexValue(tok, e.rel)
+	if err != nil {
+		return 0, err
+	}
+	layout := int(e.t.opPair.opByte[2])
+	if err := e.p.checkCompressedImmediate(e.t, imm, layout); err != nil {
+		return 0, err
+	}
+	return operand.place(base) | scatterCompressedImmediate(imm, layout), nil
+}
+
+// encodeStackMemory encodes offset(sp) of c.lwsp and c.swsp like instructions
+func encodeStackMemory(e *encoding, _ specOperand, tok *Token) (uint32, error) {
+	layout := int(e.t.opPair.opByte[2])
+	imm, err := e.p.compressedImmediate(e.t, tok, e.rel, true)
+	if err != nil {
+		return 0, err
+	}
+	if err := e.p.checkCompressedImmediate(e.t, imm, layout); err != nil {
+		return 0, err
 	}
+	return scatterCompressedImmediate(imm, layout), nil
+}
+
+func encodeRegisterList(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	if tok.tokenType != registerList {
+		return 0, errors.New(e.t.value + " is not a valid instruction")
+	}
+	return encodeNumber(e, operand, tok)
+}
+
+// encodeStackAdjustment encodes the stack_adj following the rlist of a push or pop
+func encodeStackAdjustment(e *encoding, operand specOperand, tok *Token) (uint32, error) {
+	adj, err := parseIntValue(tok.value)
+	if err != nil {
+		return 0, err
+	}
+	spimm, err := e.p.stackAdjustment(e.t, int(field(e.word, 4, 4)), adj)
+	return operand.place(spimm), err
 }
 
 // Updated parseIntValue function to handle hex values and character literals
 func parseIntValue(valueStr string) (int, error) {
+	val, err := parseInt64Value(valueStr)
+	if err != nil {
+		return 0, err
+	}
+
+	// Check if value is within 32-bit range
+	if val < math.MinInt32 || val > math.MaxUint32 {
+		return 0, fmt.Errorf("value %s is out of range for 32-bit architecture", strings.TrimSpace(valueStr))
+	}
+	return int(int32(val)), nil
+}
+
+// parseInt64Value parses the same literals as parseIntValue over the full 64-bit range,
+// unsigned hex values above MaxInt64 wrap to their two's complement value
+func parseInt64Value(valueStr string) (int64, error) {
 	// Strip whitespace
 	valueStr = strings.TrimSpace(valueStr)
 
@@ -311,15 +822,15 @@ func parseIntValue(valueStr string) (int, error) {
 		if len(char) > 1 && char[0] == '\\' {
 			switch char[1] {
 			case 'n':
-				return int('\n'), nil
+				return int64('\n'), nil
 			case 'r':
-				return int('\r'), nil
+				return int64('\r'), nil
 			case 't':
-				return int('\t'), nil
+				return int64('\t'), nil
 			case '\\':
-				return int('\\'), nil
+				return int64('\\'), nil
 			case '\'':
-				return int('\''), nil
+				return int64('\''), nil
 			case '0':
 				return 0, nil
 				//we could add more but too long
@@ -328,7 +839,7 @@ func parseIntValue(valueStr string) (int, error) {
 			}
 		} else if len(char) == 1 {
 			// Single character
-			return int(char[0]), nil
+			return int64(char[0]), nil
 		} else {
 			return 0, fmt.Errorf("invalid character literal: %s", valueStr)
 		}
@@ -344,17 +855,16 @@ func parseIntValue(valueStr string) (int, error) {
 		base = 16
 	}
 
-	// First parse as int64 to handle the full range of 32-bit unsigned values
 	val, err := strconv.ParseInt(valueStr, base, 64)
 	if err != nil {
-		return 0, err
-	}
-
-	// Check if value is within 32-bit range
-	if val < math.MinInt32 || val > math.MaxUint32 {
-		return 0, fmt.Errorf("value %s is out of range for 32-bit architecture", valueStr)
+		// values such as 0xFFFFFFFFFFFFFFFF only fit unsigned
+		uval, uerr := strconv.ParseUint(valueStr, base, 64)
+		if uerr != nil {
+			return 0, err
+		}
+		return int64(uval), nil
 	}
-	return int(int32(val)), nil
+	return val, nil
 }
 
 func (p *Program) parseComplexValue(tok *Token, relativeInstrCount int) (int, int, error) {
@@ -383,14 +893,31 @@ func (p *Program) parseComplexValue(tok *Token, relativeInstrCount int) (int, in
 				return con, int(imm), nil
 			}
 		} else {
-			parsed, err := p.parseLabelOrLiteral(tok.children[1], relativeInstrCount)
+			pos, symbol := relativeInstrCount, tok.children[1]
+			if symbol.tokenType == literal {
+				// a number is already the value, not an offset from this instruction
+				pos = 0
+			} else if tok.children[0].value == "%pcrel_lo" {
+				// the low part is relative to the auipc holding the matching %pcrel_hi
+				var err error
+				pos, symbol, err = p.compilationVariables.pcrelLoTarget(symbol, relativeInstrCount)
+				if err != nil {
+					return 0, 0, err
+				}
+			}
+			parsed, err := p.parseLabelOrLiteral(symbol, pos)
 			if err != nil {
 				return 0, 0, err
 			}
-			parsed, err = handleModifier(tok.children[0].value, parsed, relativeInstrCount)
+			parsed, err = handleModifier(tok.children[0].value, parsed, pos)
 			if err != nil {
 				return 0, 0, err
 			}
+			if len(tok.children) > 2 {
+				// %pcrel_lo(symbol)(register)
+				reg, err := p.parseLabelOrLiteral(tok.children[2], relativeInstrCount)
+				return reg, parsed, err
+			}
 			return 0, parsed, nil
 		}
 	case varLabel:
@@ -416,11 +943,13 @@ func handleModifier(mod string, val int, relativeInstrCount int) (int, error) {
 	case "%lo":
 		return (val + relativeInstrCount) & 0xFFF, nil //int(uint8(val)), nil
 	case "%hi":
-		return ((val + relativeInstrCount) >> 12) & 0xFFFFF, nil
+		// rounded up when the sign extended %lo is negative
+		return ((val + relativeInstrCount + 0x800) >> 12) & 0xFFFFF, nil
 	case "%pcrel_lo":
 		return val & 0xFFF, nil //int(uint8(val)), nil
 	case "%pcrel_hi":
-		return (val >> 12) & 0xFFFFF, nil
+		// the 0x800 carries into the upper part when the sign extended %pcrel_lo is negative
+		return ((val + 0x800) >> 12) & 0xFFFFF, nil
 	}
 	return 0, errors.New("modifier not found")
 }
@@ -769,6 +769,70 @@ func TestTranslateJType(t *testing.T) {
 	}
 }
 
+func TestTranslateR4Type(t *testing.T) {
+	type args struct {
+		opcode int
+		rd     int
+		func3  int
+		rs1    int
+		rs2    int
+		format int
+		rs3    int
+	}
+	tests := []struct {
+		name string
+		args args
+		want uint32
+	}{
+		{
+			name: "fmadd.s fa0, fa1, fa2, fa3",
+			args: args{
+				opcode: 0b1000011, // MADD opcode
+				rd:     10,
+				func3:  0b111, // dyn
+				rs1:    11,
+				rs2:    12,
+				format: 0b00, // single precision
+				rs3:    13,
+			},
+			want: 0x68C5F543,
+		},
+		{
+			name: "fnmadd.d ft0, ft1, ft2, ft3, rne",
+			args: args{
+				opcode: 0b1001111, // NMADD opcode
+				rd:     0,
+				func3:  0b000, // rne
+				rs1:    1,
+				rs2:    2,
+				format: 0b01, // double precision
+				rs3:    3,
+			},
+			want: 0x1A20804F,
+		},
+		{
+			name: "fmsub.s with all fields at max values",
+			args: args{
+				opcode: 0b1000111,
+				rd:     31,
+				func3:  0b111,
+				rs1:    31,
+				rs2:    31,
+				format: 0b11, // masked to 2 bits
+				rs3:    31,
+			},
+			want: 0xFFFFFFC7,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TranslateR4Type(tt.args.opcode, tt.args.rd, tt.args.func3, tt.args.rs1, tt.args.rs2, tt.args.format, tt.args.rs3); got != tt.want {
+				t.Errorf("TranslateR4Type() = 0x%08X, want 0x%08X", got, tt.want)
+			}
+		})
+	}
+}
+
 func TestProgram_InstructionToBinary(t *testing.T) {
 	tests := []struct {
 		name               string
@@ -846,15 +910,15 @@ func TestProgram_InstructionToBinary(t *testing.T) {
 					opByte: []byte{0b0100011, 0b010, 0b0000000}, // opcode, func3, unused for S-type
 				},
 				children: []*Token{
-					{tokenType: register, value: "x2"}, // rs1 (base)
+					{tokenType: register, value: "x2"}, // rs2 (source)
 					{tokenType: complexValue, value: "", children: []*Token{
 						{tokenType: literal, value: "8"},   // offset
-						{tokenType: register, value: "x1"}, // rs2 (source)
+						{tokenType: register, value: "x1"}, // rs1 (base)
 					}},
 				},
 			},
 			relativeInstrCount: 0,
-			want:               0b00000000000100010010010000100011, // Encoded SW x2, 8(x1)
+			want:               0b00000000001000001010010000100011, // Encoded SW x2, 8(x1)
 			wantErr:            false,
 		},
 		{
@@ -918,8 +982,8 @@ func TestProgram_InstructionToBinary(t *testing.T) {
 				tokenType: instruction,
 				value:     "ecall",
 				opPair: &OpPair{
-					opType: I,
-					opByte: []byte{0b1110011, 0b000, 0b000000000000}, // opcode, func3, func12 for ECALL
+					opType: SYS,
+					opByte: []byte{0b1110011, 0b000, 0b0000000, 0b00000}, // opcode, func3, func7, rs2 for ECALL
 				},
 			},
 			relativeInstrCount: 0,
@@ -932,8 +996,8 @@ func TestProgram_InstructionToBinary(t *testing.T) {
 				tokenType: instruction,
 				value:     "ebreak",
 				opPair: &OpPair{
-					opType: I,
-					opByte: []byte{0b1110011, 0b000, 0b000000000001}, // opcode, func3, func12 for EBREAK
+					opType: SYS,
+					opByte: []byte{0b1110011, 0b000, 0b0000000, 0b00001}, // opcode, func3, func7, rs2 for EBREAK
 				},
 			},
 			relativeInstrCount: 0,
@@ -1051,7 +1115,7 @@ func TestProgram_InstructionToBinary(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) { //reset labelPositions
-			p := &Program{}
+			p := &Program{compilationVariables: &Compilation{labelPositions: labelPositionsMockup}}
 			got, err := p.InstructionToBinary(tt.token, tt.relativeInstrCount)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("InstructionToBinary() error = %v, wantErr %v", err, tt.wantErr)
@@ -1481,6 +1545,8 @@ func TestProgram_parseComplexValue(t *testing.T) {
 				constants:   tt.fields.constants,
 				strings:     tt.fields.strings,
 				entrypoint:  tt.fields.entrypoint,
+
+				compilationVariables: &Compilation{labelPositions: labelPositionsMockup},
 			}
 			got, got1, err := p.parseComplexValue(tt.args.tok, tt.args.relativeInstrCount)
 			if (err != nil) != tt.wantErr {
@@ -1599,7 +1665,17 @@ func Test_handleModifier(t *testing.T) {
 				val:                -0x12345,
 				relativeInstrCount: 0,
 			},
-			want:    (-0x12345 >> 12) & 0xFFFFF,
+			want:    (-0x11B45 >> 12) & 0xFFFFF, // -0x12345 + 0x800
+			wantErr: false,
+		},
+		{
+			name: "%hi with carry from %lo",
+			args: args{
+				mod:                "%hi",
+				val:                0x12345FFF,
+				relativeInstrCount: 0,
+			},
+			want:    0x12346, // %lo 0xFFF is -1
 			wantErr: false,
 		},
 
@@ -1673,7 +1749,17 @@ func Test_handleModifier(t *testing.T) {
 				val:                -0x12345,
 				relativeInstrCount: 0,
 			},
-			want:    (-0x12345 >> 12) & 0xFFFFF,
+			want:    (-0x11B45 >> 12) & 0xFFFFF, // -0x12345 + 0x800
+			wantErr: false,
+		},
+		{
+			name: "%pcrel_hi with carry from %pcrel_lo",
+			args: args{
+				mod:                "%pcrel_hi",
+				val:                0x12800,
+				relativeInstrCount: 0,
+			},
+			want:    0x13, // %pcrel_lo 0x800 is -2048
 			wantErr: false,
 		},
 		{
@@ -1942,7 +2028,7 @@ func Test_parseLabelOrLiteral(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			p := Program{}
+			p := Program{compilationVariables: &Compilation{labelPositions: labelPositionsMockup}}
 			got, err := p.parseLabelOrLiteral(tt.args.tok, tt.args.instructionRelativePos)
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseLabelOrLiteral() error = %v, wantErr %v", err, tt.wantErr)